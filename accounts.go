@@ -0,0 +1,190 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package assumerole
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// accountIDPattern matches AWS account IDs, which are always 12 digits.
+var accountIDPattern = regexp.MustCompile(`^[0-9]{12}$`)
+
+// accountID resolves an account name or ID to an account ID. Names are looked
+// up in the accounts configuration, and then in AWS Organizations if enabled.
+// If a name isn't in the cached accounts from AWS Organizations, the cache is
+// refreshed in case the account is new.
+func (app *App) accountID(nameOrID string) (string, error) {
+	if accountIDPattern.MatchString(nameOrID) {
+		return nameOrID, nil
+	}
+
+	if accountID, ok := app.config.Accounts[nameOrID]; ok {
+		return accountID, nil
+	}
+
+	if !app.config.OrganizationAccounts {
+		return "", fmt.Errorf("unknown account %s: add it to accounts in the config, or enable organization_accounts", nameOrID)
+	}
+
+	accounts, err := app.organizationAccounts(false)
+	if err != nil {
+		return "", err
+	}
+
+	if accountID, ok := accounts[nameOrID]; ok {
+		return accountID, nil
+	}
+
+	if !app.orgAccountsRefreshed {
+		accounts, err = app.organizationAccounts(true)
+		if err != nil {
+			return "", err
+		}
+
+		if accountID, ok := accounts[nameOrID]; ok {
+			return accountID, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown account %s: it is not in AWS Organizations", nameOrID)
+}
+
+// roleInAccount returns the ARN of the named role in an account, given by
+// name or ID.
+func (app *App) roleInAccount(account string, roleName string) (string, error) {
+	if isValidARN(roleName) {
+		return "", fmt.Errorf("an account can't be given with a role ARN: %s", roleName)
+	}
+
+	accountID, err := app.accountID(account)
+	if err != nil {
+		return "", err
+	}
+
+	return app.iamRoleARN(accountID, roleName), nil
+}
+
+// accountName returns the name of an account for display, or its ID if the
+// name isn't known.
+func (app *App) accountName(accountID string) string {
+	if name := nameForAccountID(app.config.Accounts, accountID); name != "" {
+		return name
+	}
+
+	if app.config.OrganizationAccounts {
+		if accounts, err := app.organizationAccounts(false); err == nil {
+			if name := nameForAccountID(accounts, accountID); name != "" {
+				return name
+			}
+		}
+	}
+
+	return accountID
+}
+
+// nameForAccountID looks up the name of an account in a map of names to
+// IDs. If the account has several names, the first in sort order is used.
+func nameForAccountID(accounts map[string]string, accountID string) string {
+	var names []string
+	for name, id := range accounts {
+		if id == accountID {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return ""
+	}
+
+	sort.Strings(names)
+	return names[0]
+}
+
+// RefreshAccounts updates the cached accounts from AWS Organizations.
+func (app *App) RefreshAccounts() error {
+	_, err := app.organizationAccounts(true)
+	return err
+}
+
+// organizationAccounts returns the accounts in AWS Organizations as a map of
+// names to IDs. They are read from the cache file, unless refresh is true or
+// there is no cache yet, in which case they are listed from AWS and cached.
+func (app *App) organizationAccounts(refresh bool) (map[string]string, error) {
+	if app.orgAccounts != nil && !refresh {
+		return app.orgAccounts, nil
+	}
+
+	if !refresh && !app.noAccountsCacheFile {
+		if b, err := ioutil.ReadFile(app.accountsCacheFile); err == nil {
+			var accounts map[string]string
+			if err := json.Unmarshal(b, &accounts); err == nil {
+				app.orgAccounts = accounts
+				return accounts, nil
+			}
+		}
+	}
+
+	accounts, err := app.aws.ListAccounts()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list accounts in AWS Organizations: %v", err)
+	}
+
+	app.orgAccounts = accounts
+	app.orgAccountsRefreshed = true
+
+	if app.noAccountsCacheFile {
+		return accounts, nil
+	}
+
+	b, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(app.accountsCacheFile), 0755); err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(app.accountsCacheFile, b, 0600); err != nil {
+		return nil, fmt.Errorf("unable to cache accounts: %v", err)
+	}
+
+	return accounts, nil
+}
+
+// expandAccount replaces "{account_name}" and "{account_id}" in s with the
+// name and ID of the account. It is used for the names of cached profiles, so
+// only names from the accounts configuration are used: a name from AWS
+// Organizations would depend on whether listing the accounts worked, and
+// cache the same role under two names.
+func (app *App) expandAccount(s string, accountID string) string {
+	if strings.Contains(s, "{account_name}") {
+		name := nameForAccountID(app.config.Accounts, accountID)
+		if name == "" {
+			name = accountID
+		}
+		s = strings.Replace(s, "{account_name}", name, -1)
+	}
+
+	return strings.Replace(s, "{account_id}", accountID, -1)
+}
@@ -0,0 +1,338 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package assumerole
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// AgentSocketEnv is the environment variable with the path of the agent's
+// Unix socket. When it is set, the CLI talks to AWS and keeps its cache
+// through the agent.
+const AgentSocketEnv = "ASSUME_ROLE_AUTH_SOCK"
+
+var errAgentLocked = errors.New("the agent is locked; unlock it with assume-role unlock")
+
+// AgentOpts are the options for the agent.
+type AgentOpts struct {
+	// Lifetime is how long the agent serves before it stops, forgetting
+	// every session it holds; if it is zero, the agent serves until it's
+	// stopped
+	Lifetime time.Duration
+
+	// IdleTimeout is how long the agent serves without requests before it
+	// stops; if it is zero, the agent doesn't stop when idle
+	IdleTimeout time.Duration
+
+	// RequestTimeout is how long a client has to send its request once it
+	// has connected, and to read the response; if it is zero, the default of
+	// 10 seconds is used
+	RequestTimeout time.Duration
+
+	// CredentialsSource is where the credentials the agent assumes roles
+	// with come from, as returned by CredentialsSource for its
+	// configuration; clients cache sessions under it
+	CredentialsSource string
+}
+
+// defaultAgentRequestTimeout is the default of AgentOpts.RequestTimeout.
+const defaultAgentRequestTimeout = 10 * time.Second
+
+// maxAgentConns is how many connections the agent serves at once; further
+// connections wait to be accepted.
+const maxAgentConns = 32
+
+// Agent holds the source session, the MFA session and the cached role
+// credentials in memory, and serves the AWSProvider and AWSConfigProvider
+// calls of AgentClients over a Unix socket, like ssh-agent. This way many
+// assume-role processes share them without reading ~/.aws or calling STS
+// each time.
+type Agent struct {
+	aws       AWSProvider
+	awsConfig AWSConfigProvider
+	opts      AgentOpts
+
+	mu sync.Mutex
+
+	// lockHash is the hash of the passphrase the agent was locked with, or
+	// nil when it isn't locked
+	lockHash []byte
+
+	// requests is signalled for every request, to reset the idle timeout
+	requests chan struct{}
+
+	// conns limits the number of connections served at once
+	conns chan struct{}
+}
+
+// agentRequest is a call from an AgentClient.
+type agentRequest struct {
+	Method string
+	Params agentParams
+
+	// Credentials are the credentials to call AWS with instead of the
+	// agent's source session, as for AWSProvider.WithCredentials
+	Credentials *TemporaryCredentials `json:",omitempty"`
+}
+
+// agentParams are the parameters of all calls, of which each call uses some.
+type agentParams struct {
+	Input        *AssumeRoleInput      `json:",omitempty"`
+	MFADeviceARN string                `json:",omitempty"`
+	RoleARN      string                `json:",omitempty"`
+	MFAToken     string                `json:",omitempty"`
+	Token        string                `json:",omitempty"`
+	PrincipalARN string                `json:",omitempty"`
+	Assertion    string                `json:",omitempty"`
+	Duration     time.Duration         `json:",omitempty"`
+	ProfileName  string                `json:",omitempty"`
+	Credentials  *TemporaryCredentials `json:",omitempty"`
+	Profile      *ProfileConfiguration `json:",omitempty"`
+	Passphrase   string                `json:",omitempty"`
+}
+
+// agentResponse is the result of a call, or its error.
+type agentResponse struct {
+	Result json.RawMessage `json:",omitempty"`
+	Error  *agentError     `json:",omitempty"`
+}
+
+// agentError is an error from a call. The code of AWS errors is kept, so that
+// clients can tell e.g. access denied errors apart.
+type agentError struct {
+	Code    string `json:",omitempty"`
+	Message string
+}
+
+// NewAgent returns an agent that calls AWS through aws, and keeps the cache in
+// awsConfig (usually a MemoryAWSConfig).
+func NewAgent(aws AWSProvider, awsConfig AWSConfigProvider, opts AgentOpts) *Agent {
+	if opts.RequestTimeout == 0 {
+		opts.RequestTimeout = defaultAgentRequestTimeout
+	}
+
+	return &Agent{
+		aws:       aws,
+		awsConfig: awsConfig,
+		opts:      opts,
+		requests:  make(chan struct{}, 1),
+		conns:     make(chan struct{}, maxAgentConns),
+	}
+}
+
+// Serve serves the connections from the listener until its lifetime or idle
+// timeout is reached, when it closes the listener and returns nil, or until
+// the listener fails.
+func (a *Agent) Serve(listener net.Listener) error {
+	errs := make(chan error, 1)
+
+	go func() {
+		for {
+			a.conns <- struct{}{}
+
+			conn, err := listener.Accept()
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			go func() {
+				defer func() { <-a.conns }()
+				a.serveConn(conn)
+			}()
+		}
+	}()
+
+	var lifetime, idle <-chan time.Time
+
+	if a.opts.Lifetime > 0 {
+		lifetime = time.After(a.opts.Lifetime)
+	}
+
+	for {
+		if a.opts.IdleTimeout > 0 {
+			idle = time.After(a.opts.IdleTimeout)
+		}
+
+		select {
+		case err := <-errs:
+			return err
+		case <-lifetime:
+			return listener.Close()
+		case <-idle:
+			return listener.Close()
+		case <-a.requests:
+		}
+	}
+}
+
+// serveConn serves a single call on a connection. Clients that don't send
+// their request, or don't read the response, within the request timeout are
+// disconnected.
+func (a *Agent) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(a.opts.RequestTimeout))
+
+	var req agentRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+
+	select {
+	case a.requests <- struct{}{}:
+	default:
+	}
+
+	var resp agentResponse
+
+	result, err := a.call(&req)
+	if err == nil {
+		resp.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		resp.Error = &agentError{Message: err.Error()}
+		if awsErr, ok := err.(awserr.Error); ok {
+			resp.Error = &agentError{Code: awsErr.Code(), Message: awsErr.Message()}
+		}
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(a.opts.RequestTimeout))
+	json.NewEncoder(conn).Encode(&resp)
+}
+
+// call makes a call to AWS or the cache for a client, unless the agent is
+// locked.
+func (a *Agent) call(req *agentRequest) (interface{}, error) {
+	params := req.Params
+
+	switch req.Method {
+	case "Lock":
+		return nil, a.lock(params.Passphrase)
+	case "Unlock":
+		return nil, a.unlock(params.Passphrase)
+	}
+
+	if a.locked() {
+		return nil, errAgentLocked
+	}
+
+	aws := a.aws
+	if req.Credentials != nil {
+		aws = aws.WithCredentials(req.Credentials)
+	}
+
+	input := AssumeRoleInput{}
+	if params.Input != nil {
+		input = *params.Input
+	}
+
+	switch req.Method {
+	case "CredentialsSource":
+		return a.opts.CredentialsSource, nil
+	case "AssumeRole":
+		return aws.AssumeRole(input)
+	case "AssumeRoleWithMFA":
+		return aws.AssumeRoleWithMFA(input, params.MFADeviceARN, params.MFAToken)
+	case "AssumeRoleWithWebIdentity":
+		return aws.AssumeRoleWithWebIdentity(input, params.Token)
+	case "AssumeRoleWithSAML":
+		return aws.AssumeRoleWithSAML(input, params.PrincipalARN, params.Assertion)
+	case "GetSessionToken":
+		return aws.GetSessionToken(params.Duration, params.MFADeviceARN, params.MFAToken)
+	case "ListAccounts":
+		return aws.ListAccounts()
+	case "MFADevices":
+		return aws.MFADevices()
+	case "Username":
+		return aws.Username()
+	case "CurrentPrincipalARN":
+		return aws.CurrentPrincipalARN()
+	case "RoleMaxSessionDuration":
+		return aws.RoleMaxSessionDuration(params.RoleARN)
+
+	case "GetCredentials":
+		return a.awsConfig.GetCredentials(params.ProfileName)
+	case "SetCredentials":
+		return nil, a.awsConfig.SetCredentials(params.ProfileName, params.Credentials)
+	case "GetProfile":
+		return a.awsConfig.GetProfile(params.ProfileName)
+	case "SetProfile":
+		return nil, a.awsConfig.SetProfile(params.ProfileName, params.Profile)
+	case "ListProfiles":
+		return a.awsConfig.ListProfiles()
+	case "DeleteProfile":
+		return nil, a.awsConfig.DeleteProfile(params.ProfileName)
+	case "DeleteCredentials":
+		return nil, a.awsConfig.DeleteCredentials(params.ProfileName)
+	}
+
+	return nil, errors.New("unknown agent method: " + req.Method)
+}
+
+func (a *Agent) locked() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.lockHash != nil
+}
+
+// lock makes the agent refuse every call until it's unlocked with the same
+// passphrase.
+func (a *Agent) lock(passphrase string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.lockHash != nil {
+		return errors.New("the agent is already locked")
+	}
+
+	if passphrase == "" {
+		return errors.New("a passphrase is required to lock the agent")
+	}
+
+	hash := sha256.Sum256([]byte(passphrase))
+	a.lockHash = hash[:]
+
+	return nil
+}
+
+// unlock unlocks the agent if the passphrase is the one it was locked with.
+func (a *Agent) unlock(passphrase string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.lockHash == nil {
+		return errors.New("the agent is not locked")
+	}
+
+	hash := sha256.Sum256([]byte(passphrase))
+	if subtle.ConstantTimeCompare(hash[:], a.lockHash) != 1 {
+		return errors.New("incorrect passphrase")
+	}
+
+	a.lockHash = nil
+
+	return nil
+}
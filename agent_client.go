@@ -0,0 +1,231 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package assumerole
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// agentDialTimeout is how long to wait to connect to the agent.
+const agentDialTimeout = 5 * time.Second
+
+// AgentClient is an AWSProvider and AWSConfigProvider that makes its calls
+// through an Agent, so that the source session and the cached credentials
+// are the agent's.
+type AgentClient struct {
+	socketPath string
+
+	// credentials are the credentials the agent calls AWS with, if they were
+	// given with WithCredentials
+	credentials *TemporaryCredentials
+}
+
+// NewAgentClient returns a client for the agent listening on the Unix socket
+// at socketPath.
+func NewAgentClient(socketPath string) *AgentClient {
+	return &AgentClient{socketPath: socketPath}
+}
+
+// call makes a call to the agent, and decodes its result into result unless
+// it is nil.
+func (c *AgentClient) call(method string, params agentParams, result interface{}) error {
+	conn, err := net.DialTimeout("unix", c.socketPath, agentDialTimeout)
+	if err != nil {
+		return fmt.Errorf("could not connect to the agent at %s (%s): %v", c.socketPath, AgentSocketEnv, err)
+	}
+	defer conn.Close()
+
+	req := agentRequest{
+		Method:      method,
+		Params:      params,
+		Credentials: c.credentials,
+	}
+	if err := json.NewEncoder(conn).Encode(&req); err != nil {
+		return fmt.Errorf("could not call the agent: %v", err)
+	}
+
+	var resp agentResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("could not read the response of the agent: %v", err)
+	}
+
+	if resp.Error != nil {
+		if resp.Error.Code != "" {
+			return awserr.New(resp.Error.Code, resp.Error.Message, nil)
+		}
+		return errors.New(resp.Error.Message)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(resp.Result, result)
+}
+
+// callCredentials makes a call that returns credentials.
+func (c *AgentClient) callCredentials(method string, params agentParams) (*TemporaryCredentials, error) {
+	var creds *TemporaryCredentials
+	if err := c.call(method, params, &creds); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// Lock locks the agent, which then refuses every call until it's unlocked
+// with the same passphrase.
+func (c *AgentClient) Lock(passphrase string) error {
+	return c.call("Lock", agentParams{Passphrase: passphrase}, nil)
+}
+
+// Unlock unlocks the agent.
+func (c *AgentClient) Unlock(passphrase string) error {
+	return c.call("Unlock", agentParams{Passphrase: passphrase}, nil)
+}
+
+// CredentialsSource returns where the credentials the agent assumes roles
+// with come from, which sessions assumed through it are cached under.
+func (c *AgentClient) CredentialsSource() (string, error) {
+	var source string
+	if err := c.call("CredentialsSource", agentParams{}, &source); err != nil {
+		return "", err
+	}
+	return source, nil
+}
+
+// AssumeRole calls sts:AssumeRole through the agent.
+func (c *AgentClient) AssumeRole(input AssumeRoleInput) (*TemporaryCredentials, error) {
+	return c.callCredentials("AssumeRole", agentParams{Input: &input})
+}
+
+// AssumeRoleWithMFA calls sts:AssumeRole with an MFA token through the agent.
+func (c *AgentClient) AssumeRoleWithMFA(input AssumeRoleInput, mfaDeviceARN string, mfaToken string) (*TemporaryCredentials, error) {
+	return c.callCredentials("AssumeRoleWithMFA", agentParams{Input: &input, MFADeviceARN: mfaDeviceARN, MFAToken: mfaToken})
+}
+
+// AssumeRoleWithWebIdentity calls sts:AssumeRoleWithWebIdentity through the
+// agent.
+func (c *AgentClient) AssumeRoleWithWebIdentity(input AssumeRoleInput, token string) (*TemporaryCredentials, error) {
+	return c.callCredentials("AssumeRoleWithWebIdentity", agentParams{Input: &input, Token: token})
+}
+
+// AssumeRoleWithSAML calls sts:AssumeRoleWithSAML through the agent.
+func (c *AgentClient) AssumeRoleWithSAML(input AssumeRoleInput, principalARN string, assertion string) (*TemporaryCredentials, error) {
+	return c.callCredentials("AssumeRoleWithSAML", agentParams{Input: &input, PrincipalARN: principalARN, Assertion: assertion})
+}
+
+// GetSessionToken calls sts:GetSessionToken through the agent.
+func (c *AgentClient) GetSessionToken(duration time.Duration, mfaDeviceARN string, mfaToken string) (*TemporaryCredentials, error) {
+	return c.callCredentials("GetSessionToken", agentParams{Duration: duration, MFADeviceARN: mfaDeviceARN, MFAToken: mfaToken})
+}
+
+// ListAccounts lists the accounts of the organization through the agent.
+func (c *AgentClient) ListAccounts() (map[string]string, error) {
+	var accounts map[string]string
+	if err := c.call("ListAccounts", agentParams{}, &accounts); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// MFADevices returns the MFA devices of the agent's IAM user.
+func (c *AgentClient) MFADevices() ([]string, error) {
+	var devices []string
+	if err := c.call("MFADevices", agentParams{}, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// Username returns the name of the agent's IAM user.
+func (c *AgentClient) Username() (string, error) {
+	var username string
+	err := c.call("Username", agentParams{}, &username)
+	return username, err
+}
+
+// CurrentPrincipalARN returns the ARN of the principal the agent calls AWS as.
+func (c *AgentClient) CurrentPrincipalARN() (string, error) {
+	var principalARN string
+	err := c.call("CurrentPrincipalARN", agentParams{}, &principalARN)
+	return principalARN, err
+}
+
+// RoleMaxSessionDuration returns the MaxSessionDuration of a role, looked up
+// by the agent.
+func (c *AgentClient) RoleMaxSessionDuration(roleARN string) (time.Duration, error) {
+	var duration time.Duration
+	err := c.call("RoleMaxSessionDuration", agentParams{RoleARN: roleARN}, &duration)
+	return duration, err
+}
+
+// WithCredentials returns a client whose calls the agent makes with the given
+// credentials.
+func (c *AgentClient) WithCredentials(creds *TemporaryCredentials) AWSProvider {
+	return &AgentClient{
+		socketPath:  c.socketPath,
+		credentials: creds,
+	}
+}
+
+// GetCredentials returns the credentials of a profile cached by the agent.
+func (c *AgentClient) GetCredentials(profileName string) (*TemporaryCredentials, error) {
+	return c.callCredentials("GetCredentials", agentParams{ProfileName: profileName})
+}
+
+// SetCredentials caches the credentials of a profile in the agent.
+func (c *AgentClient) SetCredentials(profileName string, creds *TemporaryCredentials) error {
+	return c.call("SetCredentials", agentParams{ProfileName: profileName, Credentials: creds}, nil)
+}
+
+// GetProfile returns a profile cached by the agent.
+func (c *AgentClient) GetProfile(profileName string) (*ProfileConfiguration, error) {
+	var profile *ProfileConfiguration
+	if err := c.call("GetProfile", agentParams{ProfileName: profileName}, &profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// SetProfile caches a profile in the agent.
+func (c *AgentClient) SetProfile(profileName string, profile *ProfileConfiguration) error {
+	return c.call("SetProfile", agentParams{ProfileName: profileName, Profile: profile}, nil)
+}
+
+// ListProfiles returns the names of the profiles cached by the agent.
+func (c *AgentClient) ListProfiles() ([]string, error) {
+	var names []string
+	if err := c.call("ListProfiles", agentParams{}, &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// DeleteProfile removes a profile from the agent.
+func (c *AgentClient) DeleteProfile(profileName string) error {
+	return c.call("DeleteProfile", agentParams{ProfileName: profileName}, nil)
+}
+
+// DeleteCredentials removes the credentials of a profile from the agent.
+func (c *AgentClient) DeleteCredentials(profileName string) error {
+	return c.call("DeleteCredentials", agentParams{ProfileName: profileName}, nil)
+}
@@ -0,0 +1,236 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package assumerole_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	assumerole "github.com/uber/assume-role-cli"
+	"github.com/uber/assume-role-cli/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testAgent is an agent with a mock AWSProvider and an in-memory cache.
+type testAgent struct {
+	Client  *assumerole.AgentClient
+	MockAWS *mocks.MockAWSProvider
+
+	// Done receives the result of Serve
+	Done <-chan error
+
+	mockCtrl *gomock.Controller
+	listener net.Listener
+	dir      string
+}
+
+func startTestAgent(t *testing.T, opts assumerole.AgentOpts) *testAgent {
+	mockCtrl := gomock.NewController(t)
+	mockAWS := mocks.NewMockAWSProvider(mockCtrl)
+
+	dir, err := ioutil.TempDir("", "assume-role-agent-test")
+	require.NoError(t, err)
+
+	socketPath := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+
+	agent := assumerole.NewAgent(mockAWS, assumerole.NewMemoryAWSConfig(), opts)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- agent.Serve(listener)
+	}()
+
+	return &testAgent{
+		Client:   assumerole.NewAgentClient(socketPath),
+		MockAWS:  mockAWS,
+		Done:     done,
+		mockCtrl: mockCtrl,
+		listener: listener,
+		dir:      dir,
+	}
+}
+
+// Close stops the agent and checks the calls to the mock.
+func (a *testAgent) Close() {
+	a.listener.Close()
+	os.RemoveAll(a.dir)
+	a.mockCtrl.Finish()
+}
+
+func TestAgentAWSProvider(t *testing.T) {
+	agent := startTestAgent(t, assumerole.AgentOpts{})
+	defer agent.Close()
+	client, mockAWS := agent.Client, agent.MockAWS
+
+	input := assumeRoleInput(fooProfileWithMFA.RoleARN, "bob")
+
+	mockAWS.EXPECT().AssumeRole(input).Return(nil, awsAccessDeniedError)
+	mockAWS.EXPECT().AssumeRoleWithMFA(input, fooProfileWithMFA.MFASerial, "123456").Return(fooCredentials, nil)
+	mockAWS.EXPECT().MFADevices().Return([]string{fooProfileWithMFA.MFASerial}, nil)
+
+	// AWS errors keep their code, so that the MFA fallback still works
+	_, err := client.AssumeRole(input)
+	require.Error(t, err)
+	assert.True(t, assumerole.IsAWSAccessDeniedError(err))
+
+	creds, err := client.AssumeRoleWithMFA(input, fooProfileWithMFA.MFASerial, "123456")
+	require.NoError(t, err)
+	assert.Equal(t, fooCredentials.AccessKeyID, creds.AccessKeyID)
+	assert.Equal(t, fooCredentials.SessionToken, creds.SessionToken)
+	assert.True(t, fooCredentials.Expires.Equal(creds.Expires))
+
+	devices, err := client.MFADevices()
+	require.NoError(t, err)
+	assert.Equal(t, []string{fooProfileWithMFA.MFASerial}, devices)
+}
+
+func TestAgentWithCredentials(t *testing.T) {
+	agent := startTestAgent(t, assumerole.AgentOpts{})
+	defer agent.Close()
+	client, mockAWS := agent.Client, agent.MockAWS
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockAWSWithCredentials := mocks.NewMockAWSProvider(mockCtrl)
+
+	mockAWS.EXPECT().WithCredentials(gomock.Any()).DoAndReturn(func(creds *assumerole.TemporaryCredentials) assumerole.AWSProvider {
+		assert.Equal(t, fooCredentials.AccessKeyID, creds.AccessKeyID)
+		return mockAWSWithCredentials
+	})
+	mockAWSWithCredentials.EXPECT().CurrentPrincipalARN().Return("arn:aws:sts::000000000000:assumed-role/testRole/bob", nil)
+
+	principalARN, err := client.WithCredentials(fooCredentials).CurrentPrincipalARN()
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws:sts::000000000000:assumed-role/testRole/bob", principalARN)
+}
+
+func TestAgentAWSConfigProvider(t *testing.T) {
+	agent := startTestAgent(t, assumerole.AgentOpts{})
+	defer agent.Close()
+	client := agent.Client
+
+	expires := time.Date(2018, 04, 23, 13, 0, 0, 0, time.UTC)
+
+	require.NoError(t, client.SetProfile("000000000000-testRole", &assumerole.ProfileConfiguration{
+		Expires: expires,
+		RoleARN: fooProfileWithMFA.RoleARN,
+	}))
+	require.NoError(t, client.SetCredentials("000000000000-testRole", &assumerole.TemporaryCredentials{
+		AccessKeyID: "ABC123",
+		Expires:     expires,
+	}))
+
+	names, err := client.ListProfiles()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"000000000000-testRole"}, names)
+
+	profile, err := client.GetProfile("000000000000-testRole")
+	require.NoError(t, err)
+	assert.Equal(t, fooProfileWithMFA.RoleARN, profile.RoleARN)
+	assert.True(t, expires.Equal(profile.Expires))
+
+	creds, err := client.GetCredentials("000000000000-testRole")
+	require.NoError(t, err)
+	assert.Equal(t, "ABC123", creds.AccessKeyID)
+
+	require.NoError(t, client.DeleteCredentials("000000000000-testRole"))
+	require.NoError(t, client.DeleteProfile("000000000000-testRole"))
+
+	names, err = client.ListProfiles()
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	// Profiles that don't exist are empty, as with ~/.aws
+	profile, err = client.GetProfile("000000000000-testRole")
+	require.NoError(t, err)
+	assert.Equal(t, &assumerole.ProfileConfiguration{}, profile)
+}
+
+func TestAgentLock(t *testing.T) {
+	agent := startTestAgent(t, assumerole.AgentOpts{})
+	defer agent.Close()
+	client := agent.Client
+
+	require.NoError(t, client.Lock("hunter2"))
+
+	_, err := client.ListProfiles()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the agent is locked")
+
+	assert.Error(t, client.Lock("hunter2"))
+	assert.EqualError(t, client.Unlock("wrong"), "incorrect passphrase")
+
+	require.NoError(t, client.Unlock("hunter2"))
+
+	_, err = client.ListProfiles()
+	assert.NoError(t, err)
+
+	assert.EqualError(t, client.Unlock("hunter2"), "the agent is not locked")
+}
+
+func TestAgentIdleTimeout(t *testing.T) {
+	agent := startTestAgent(t, assumerole.AgentOpts{IdleTimeout: 50 * time.Millisecond})
+	defer agent.Close()
+	client := agent.Client
+
+	select {
+	case err := <-agent.Done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the agent didn't stop when idle")
+	}
+
+	_, err := client.ListProfiles()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "could not connect to the agent")
+}
+
+func TestAgentCredentialsSource(t *testing.T) {
+	agent := startTestAgent(t, assumerole.AgentOpts{CredentialsSource: "source_profile=work"})
+	defer agent.Close()
+
+	source, err := agent.Client.CredentialsSource()
+	require.NoError(t, err)
+	assert.Equal(t, "source_profile=work", source)
+}
+
+func TestAgentRequestTimeout(t *testing.T) {
+	agent := startTestAgent(t, assumerole.AgentOpts{RequestTimeout: 50 * time.Millisecond})
+	defer agent.Close()
+
+	conn, err := net.Dial("unix", filepath.Join(agent.dir, "agent.sock"))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// A client that never sends its request is disconnected
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+
+	// and the agent still serves others
+	_, err = agent.Client.ListProfiles()
+	assert.NoError(t, err)
+}
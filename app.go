@@ -20,17 +20,23 @@ package assumerole
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/hashicorp/go-multierror"
 	"golang.org/x/crypto/ssh/terminal"
 )
@@ -45,164 +51,1061 @@ type App struct {
 	stdin     io.Reader
 
 	stdinReader *bufio.Reader
+	projectTags map[string]string
+
+	samlAssertionValue string
+
+	// principalARN and username are those of the current IAM principal,
+	// which are only looked up once
+	principalARN string
+	username     string
+
+	// roleMaxDurations holds the MaxSessionDuration found for roles whose
+	// limit was exceeded, so that later calls start from it
+	roleMaxDurations map[string]time.Duration
+
+	// promptNotice is printed before the next prompt for MFA, to explain a
+	// prompt that doesn't come straight after running assume-role
+	promptNotice string
+
+	// noPrompts makes prompts fail instead, while a command that assume-role
+	// runs has the terminal
+	noPrompts bool
+
+	// cacheOnly makes assuming a role fail with errCachedCredentialsExpired
+	// rather than call AWS when the cached credentials need refreshing
+	cacheOnly bool
+
+	// credentialsSourceOverride is where the credentials that roles are
+	// assumed with come from, when they aren't this process's
+	credentialsSourceOverride *string
+
+	accountsCacheFile    string
+	noAccountsCacheFile  bool
+	orgAccounts          map[string]string
+	orgAccountsRefreshed bool
+}
+
+// AssumeRoleParameters are the parameters for the AssumeRole call
+type AssumeRoleParameters struct {
+	// UserRole is the ARN of the role to be assumed
+	UserRole string
+
+	// RoleSessionName is the session name for the AWS AssumeRole call; if it is
+	// the empty string, the current username will be used
+	RoleSessionName string
+
+	// When ForceRefresh is true, assumerole will bypass the local cache and do a
+	// call to sts:AssumeRole to retrieve fresh credentials.
+	ForceRefresh bool
+
+	// Duration is the requested lifetime of the credentials; if it is zero,
+	// the duration from the role's configuration or the top-level
+	// configuration will be used
+	Duration time.Duration
+
+	// Via is a list of roles (names or ARNs) that are assumed in order before
+	// UserRole, each using the credentials of the one before it. If it is
+	// empty, the chain from the role's configuration will be used.
+	Via []string
+
+	// Policy is an inline session policy (a JSON policy document) that scopes
+	// down the permissions of the assumed role; if it is empty, the policy
+	// from the role's configuration will be used
+	Policy string
+
+	// PolicyARNs are the ARNs of managed policies used as session policies; if
+	// it is empty, the policy ARNs from the role's configuration will be used
+	PolicyARNs []string
+
+	// Tags are session tags, which are merged with the tags from the
+	// configuration
+	Tags map[string]string
+
+	// TransitiveTagKeys are the keys of the tags that are passed on to the
+	// sessions of roles assumed using these credentials; they must be keys
+	// of the session tags
+	TransitiveTagKeys []string
+
+	// ExternalID is the external ID required by the role's trust policy,
+	// usually for roles in third-party accounts; if it is empty, the
+	// external ID from the role's configuration will be used
+	ExternalID string
+
+	// SourceIdentity identifies the person behind the session in CloudTrail,
+	// and is kept for roles assumed from this one; if it is empty, the source
+	// identity from the configuration will be used
+	SourceIdentity string
+
+	// Account is the name or ID of the account of UserRole, which is then a
+	// role name; if it is empty, UserRole is resolved as usual
+	Account string
+}
+
+// Limits on the session duration imposed by sts:AssumeRole. Every role allows
+// sessions of at least an hour, which is the default MaxSessionDuration.
+const (
+	minSessionDuration = 15 * time.Minute
+	maxSessionDuration = 12 * time.Hour
+	minRoleMaxDuration = time.Hour
+
+	// maxChainedSessionDuration is the limit for roles assumed using the
+	// credentials of another role
+	maxChainedSessionDuration = time.Hour
+
+	// maxMFASessionDuration is the limit for sts:GetSessionToken sessions of
+	// IAM users
+	maxMFASessionDuration = 36 * time.Hour
+)
+
+// mfaSessionProfilePrefix is the prefix of the name of the profile that the
+// MFA session is cached under.
+const mfaSessionProfilePrefix = "assume-role-mfa-session-"
+
+// defaultIdentityProviderSessionName is the session name used with a web
+// identity or SAML when none is configured.
+const defaultIdentityProviderSessionName = "assume-role"
+
+// used here and in tests
+var errAssumedRoleNeedsSessionName = errors.New("Validation error: missing role session name when current IAM principal is an assumed role")
+
+// errCachedCredentialsExpired is returned instead of calling AWS when the
+// cached credentials need refreshing and only the cache may be used.
+var errCachedCredentialsExpired = errors.New("the cached credentials need refreshing")
+
+// PromptRequiredError is returned when assuming a role needs to prompt the
+// user for input, e.g. an MFA token, while prompts are disabled.
+type PromptRequiredError struct {
+	// Input is what the user would have been prompted for
+	Input string
+}
+
+func (e *PromptRequiredError) Error() string {
+	return fmt.Sprintf("unable to prompt for %s while the command is running; assume the role again in another terminal to refresh its cached credentials", e.Input)
+}
+
+// IsPromptRequiredError indicates whether assuming a role failed because the
+// user would have had to be prompted while prompts are disabled.
+func IsPromptRequiredError(err error) bool {
+	return promptRequiredError(err) != nil
+}
+
+// promptRequiredError returns the PromptRequiredError that err is or holds, or
+// nil if there is none.
+func promptRequiredError(err error) *PromptRequiredError {
+	var multiErr *multierror.Error
+	if errors.As(err, &multiErr) {
+		for _, err := range multiErr.Errors {
+			if promptErr := promptRequiredError(err); promptErr != nil {
+				return promptErr
+			}
+		}
+		return nil
+	}
+
+	var promptErr *PromptRequiredError
+	if errors.As(err, &promptErr) {
+		return promptErr
+	}
+	return nil
+}
+
+// NewApp creates a new App.
+func NewApp(opts ...Option) (*App, error) {
+	app := &App{
+		stdin:  os.Stdin,
+		stderr: os.Stderr,
+	}
+
+	if err := app.applyOptions(opts...); err != nil {
+		return nil, err
+	}
+
+	if err := app.setDefaults(); err != nil {
+		return nil, err
+	}
+
+	app.stdinReader = bufio.NewReader(app.stdin)
+
+	return app, nil
+}
+
+// AssumeRole takes a role name and calls AWS AssumeRole, returning a
+// set of temporary credentials. If MFA is required, it will prompt for
+// an MFA token interactively.
+func (app *App) AssumeRole(options AssumeRoleParameters) (*TemporaryCredentials, error) {
+	if app.config.WebIdentity != nil && app.config.SAML != nil {
+		return nil, errors.New("only one of web_identity and saml can be configured")
+	}
+
+	// A role in another account is given by the account and the role name
+	if options.Account != "" {
+		roleARN, err := app.roleInAccount(options.Account, options.UserRole)
+		if err != nil {
+			return nil, err
+		}
+		options.UserRole = roleARN
+	}
+
+	if options.RoleSessionName == "" {
+		options.RoleSessionName = app.config.Roles[options.UserRole].RoleSessionName
+	}
+
+	chain, err := app.roleChain(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(chain) == 1 && !app.config.usesIdentityProvider() {
+		return app.assumeRole(options)
+	}
+
+	// All roles in the chain share a session name, which can't be looked up
+	// from AWS once we're using the credentials of an assumed role.
+	if options.RoleSessionName == "" {
+		sessionName, err := app.defaultSessionName()
+		if err != nil {
+			return nil, err
+		}
+		options.RoleSessionName = sessionName
+	}
+
+	return app.assumeRoleChain(chain, options)
+}
+
+// roleChain returns the roles to assume in order, ending with the requested
+// role. When the first role of the chain is configured to be assumed via other
+// roles itself, those come before it, and so on. When using an identity
+// provider with a role ARN, that role comes first.
+func (app *App) roleChain(options AssumeRoleParameters) ([]string, error) {
+	via := options.Via
+	if len(via) == 0 {
+		via = app.config.Roles[options.UserRole].Via
+	}
+
+	chain := append(append([]string{}, via...), options.UserRole)
+
+	seen := make(map[string]bool)
+	for _, role := range chain {
+		seen[role] = true
+	}
+
+	for {
+		firstVia := app.config.Roles[chain[0]].Via
+		if len(chain) == 1 || len(firstVia) == 0 {
+			break
+		}
+
+		for _, role := range firstVia {
+			if seen[role] {
+				return nil, fmt.Errorf("invalid via configuration for role %s: the chain of roles loops back to %s", chain[0], role)
+			}
+			seen[role] = true
+		}
+
+		chain = append(append([]string{}, firstVia...), chain...)
+	}
+
+	if identityProviderRoleARN := app.config.identityProviderRoleARN(); identityProviderRoleARN != "" {
+		firstRoleARN, err := app.roleARN(chain[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if firstRoleARN != identityProviderRoleARN {
+			chain = append([]string{identityProviderRoleARN}, chain...)
+		}
+	}
+
+	return chain, nil
+}
+
+// assumeRole assumes a single role using the current IAM principal.
+func (app *App) assumeRole(options AssumeRoleParameters) (*TemporaryCredentials, error) {
+	input, err := app.assumeRoleInput(options)
+	if err != nil {
+		return nil, err
+	}
+
+	profileName, err := app.profileName(options.UserRole, app.sessionHash(input, app.credentialsSource(), nil))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.validateDuration(input.Duration); err != nil {
+		return nil, err
+	}
+
+	profile, err := app.awsConfig.GetProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		profile = &ProfileConfiguration{}
+	}
+
+	currentPrincipalIsAssumedRole, err := app.principalIsAssumedRole()
+	if err != nil {
+		return nil, fmt.Errorf("unable to check IAM principal type: %v", err)
+	}
+
+	// If force refresh was requested, or the credentials from a previous session
+	// are still valid return those
+	if !app.credentialsExpired(profile.Expires) && !options.ForceRefresh {
+		return app.awsConfig.GetCredentials(profileName)
+	}
+
+	if app.cacheOnly {
+		return nil, errCachedCredentialsExpired
+	}
+
+	roleARN, err := app.roleARN(options.UserRole)
+	if err != nil {
+		return nil, err
+	}
+	profile.RoleARN = roleARN
+	profile.ExternalID = input.ExternalID
+	profile.Region = app.Region(options.UserRole)
+	profile.RoleAlias = app.roleAlias(options.UserRole)
+	profile.SourceProfile = app.config.SourceProfile
+	// The source identity is set on every role in a chain; AWS requires it
+	// to be the same throughout.
+	if input.SourceIdentity == "" {
+		if input.SourceIdentity, err = app.sourceIdentity(); err != nil {
+			return nil, err
+		}
+	}
+	profile.SourceIdentity = input.SourceIdentity
+
+	sessionName := profile.RoleSessionName
+	if sessionName == "" {
+		if options.RoleSessionName != "" {
+			sessionName = options.RoleSessionName
+		} else {
+			if currentPrincipalIsAssumedRole {
+				return nil, errAssumedRoleNeedsSessionName
+			}
+			sessionName, err = app.currentUsername()
+			if err != nil {
+				return nil, fmt.Errorf("unable to get username from AWS: %v", err)
+			}
+		}
+		profile.RoleSessionName = sessionName
+	}
+
+	input.RoleARN = roleARN
+	input.RoleSessionName = sessionName
+
+	// We first try to assume role without MFA and if that doesn't work then we
+	// try to assume role with MFA. Along the way, we collect errors in a
+	// multierr, so that if there is a fatal problem then we can output all
+	// errors so the user can see what happened along the way.
+	var finalErr error
+
+	// Try to assume role without MFA, unless the role is configured to
+	// always require it
+	var creds *TemporaryCredentials
+	if !app.config.Roles[options.UserRole].MFA || currentPrincipalIsAssumedRole {
+		creds, err = app.callAssumeRole(app.aws, input, app.aws.AssumeRole)
+		if err != nil {
+			if IsAWSAccessDeniedError(err) {
+				finalErr = multierror.Append(finalErr, fmt.Errorf("error trying to AssumeRole without MFA: %v", err))
+			} else {
+				// Fail immediately if the error was something other than "access denied"
+				return nil, err
+			}
+		}
+	}
+	if creds != nil {
+		profile.Expires = creds.Expires
+
+		// Save credentials
+		if err := app.save(profileName, profile, creds); err != nil {
+			return nil, err
+		}
+
+		return creds, nil
+	}
+
+	if currentPrincipalIsAssumedRole {
+		// assumed roles don't have an user name or MFA device associated with them
+		return nil, finalErr
+	}
+
+	if app.config.MFASession {
+		// Use the cached MFA session rather than prompting for a token
+		mfaSession, mfaDeviceARN, err := app.mfaSession()
+		if err != nil {
+			finalErr = multierror.Append(finalErr, fmt.Errorf("error trying to AssumeRole with MFA session: %w", err))
+			return nil, finalErr
+		}
+		profile.MFASerial = mfaDeviceARN
+
+		mfaAWS := app.aws.WithCredentials(mfaSession)
+		creds, err = app.callAssumeRole(mfaAWS, input, mfaAWS.AssumeRole)
+		if err != nil {
+			finalErr = multierror.Append(finalErr, fmt.Errorf("error trying to AssumeRole with MFA session: %v; giving up", err))
+			return nil, finalErr
+		}
+	} else {
+		// Get user's MFA device
+		mfaDeviceARN, err := app.mfaDevice()
+		if err != nil {
+			finalErr = multierror.Append(finalErr, fmt.Errorf("error trying to AssumeRole with MFA: %w", err))
+			return nil, finalErr
+		}
+		profile.MFASerial = mfaDeviceARN
+
+		// Get token
+		mfaToken, err := app.mfaToken()
+		if err != nil {
+			finalErr = multierror.Append(finalErr, fmt.Errorf("error trying to AssumeRole with MFA: %w", err))
+			return nil, finalErr
+		}
+
+		// Assume role
+		creds, err = app.callAssumeRole(app.aws, input, func(input AssumeRoleInput) (*TemporaryCredentials, error) {
+			return app.aws.AssumeRoleWithMFA(input, mfaDeviceARN, mfaToken)
+		})
+		if err != nil {
+			finalErr = multierror.Append(finalErr, fmt.Errorf("error trying to AssumeRole with MFA: %v; giving up", err))
+			return nil, finalErr
+		}
+	}
+	profile.Expires = creds.Expires
+
+	// Save credentials
+	if err := app.save(profileName, profile, creds); err != nil {
+		return nil, err
+	}
+
+	return creds, nil
+}
+
+// mfaSession returns the credentials of the cached MFA session, along with the
+// MFA device it was created with. If there is no valid session, a new one is
+// created with sts:GetSessionToken, prompting for an MFA token. The session is
+// cached under its own profile, separately from the role credentials.
+func (app *App) mfaSession() (*TemporaryCredentials, string, error) {
+	profileName, err := app.mfaSessionProfileName()
+	if err != nil {
+		return nil, "", err
+	}
+
+	profile, err := app.awsConfig.GetProfile(profileName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if profile != nil && !app.credentialsExpired(profile.Expires) {
+		creds, err := app.awsConfig.GetCredentials(profileName)
+		if err != nil {
+			return nil, "", err
+		}
+		return creds, profile.MFASerial, nil
+	}
+
+	duration := app.config.MFASessionDuration
+	if duration < minSessionDuration || duration > maxMFASessionDuration {
+		return nil, "", fmt.Errorf("invalid MFA session duration %v: must be between %v and %v", duration, minSessionDuration, maxMFASessionDuration)
+	}
+
+	mfaDeviceARN, err := app.mfaDevice()
+	if err != nil {
+		return nil, "", err
+	}
+
+	mfaToken, err := app.mfaToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	creds, err := app.aws.GetSessionToken(duration, mfaDeviceARN, mfaToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	profile = &ProfileConfiguration{
+		Expires:   creds.Expires,
+		MFASerial: mfaDeviceARN,
+	}
+
+	if err := app.save(profileName, profile, creds); err != nil {
+		return nil, "", err
+	}
+
+	return creds, mfaDeviceARN, nil
+}
+
+// mfaSessionProfileName returns the name of the profile the MFA session of the
+// current IAM user is cached under.
+func (app *App) mfaSessionProfileName() (string, error) {
+	principalARN, err := app.currentPrincipalARN()
+	if err != nil {
+		return "", fmt.Errorf("unable to get IAM principal: %v", err)
+	}
+
+	parsedARN, err := arn.Parse(principalARN)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%s-%s", mfaSessionProfilePrefix, parsedARN.AccountID, filepath.Base(parsedARN.Resource)), nil
+}
+
+// assumeRoleChain assumes the last role in the chain, using the credentials of
+// the role before it, which are in turn retrieved the same way. The first role
+// in the chain is assumed using the current IAM principal. Credentials for
+// every role in the chain are cached under their own profile.
+func (app *App) assumeRoleChain(chain []string, options AssumeRoleParameters) (*TemporaryCredentials, error) {
+	options.UserRole = chain[len(chain)-1]
+
+	if len(chain) == 1 {
+		if app.config.usesIdentityProvider() {
+			return app.assumeRoleWithIdentityProvider(options)
+		}
+		return app.assumeRole(options)
+	}
+
+	// The requested duration, session policy, tags and external ID only
+	// apply to the target role; the roles before it use their own
+	// configuration.
+	sourceOptions := options
+	sourceOptions.Duration = 0
+	sourceOptions.Policy = ""
+	sourceOptions.PolicyARNs = nil
+	sourceOptions.Tags = nil
+	sourceOptions.TransitiveTagKeys = nil
+	sourceOptions.ExternalID = ""
+
+	input, err := app.assumeRoleInput(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Transitive tags from the roles before this one are passed on to this
+	// session by AWS, and can't be set again. The roles before this one are
+	// part of the cache key, so that the session doesn't replace that of the
+	// same role assumed directly or via other roles.
+	var viaARNs []string
+	for _, sourceRole := range chain[:len(chain)-1] {
+		sourceOptions.UserRole = sourceRole
+
+		_, transitiveTagKeys, err := app.sessionTags(sourceOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		input.Tags, input.TransitiveTagKeys = withoutTags(input.Tags, input.TransitiveTagKeys, transitiveTagKeys)
+
+		sourceRoleARN, err := app.roleARN(sourceRole)
+		if err != nil {
+			return nil, err
+		}
+		viaARNs = append(viaARNs, sourceRoleARN)
+	}
+
+	profileName, err := app.profileName(options.UserRole, app.sessionHash(input, app.credentialsSource(), viaARNs))
+	if err != nil {
+		return nil, err
+	}
+
+	roleARN, err := app.roleARN(options.UserRole)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Duration > maxChainedSessionDuration {
+		fmt.Fprintf(app.stderr, "WARNING: session duration of %v exceeds the limit for roles assumed via another role; using %v for %s\n",
+			input.Duration, maxChainedSessionDuration, roleARN)
+		input.Duration = maxChainedSessionDuration
+	}
+	if err := app.validateDuration(input.Duration); err != nil {
+		return nil, err
+	}
+
+	profile, err := app.awsConfig.GetProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		profile = &ProfileConfiguration{}
+	}
+
+	if !app.credentialsExpired(profile.Expires) && !options.ForceRefresh {
+		return app.awsConfig.GetCredentials(profileName)
+	}
+
+	if app.cacheOnly {
+		return nil, errCachedCredentialsExpired
+	}
+
+	sourceCreds, err := app.assumeRoleChain(chain[:len(chain)-1], sourceOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	profile.RoleARN = roleARN
+	profile.ExternalID = input.ExternalID
+	profile.RoleSessionName = options.RoleSessionName
+	profile.MFASerial = ""
+	profile.Region = app.Region(options.UserRole)
+	profile.RoleAlias = app.roleAlias(options.UserRole)
+	// Like the first role in the chain, the session goes back to the source
+	// profile, unless the chain starts from an identity provider
+	profile.SourceProfile = ""
+	if !app.config.usesIdentityProvider() {
+		profile.SourceProfile = app.config.SourceProfile
+	}
+	// The source identity is set on every role in a chain; AWS requires it
+	// to be the same throughout.
+	if input.SourceIdentity == "" {
+		if input.SourceIdentity, err = app.sourceIdentity(); err != nil {
+			return nil, err
+		}
+	}
+	profile.SourceIdentity = input.SourceIdentity
+
+	input.RoleARN = roleARN
+	input.RoleSessionName = options.RoleSessionName
+
+	sourceAWS := app.aws.WithCredentials(sourceCreds)
+	creds, err := app.callAssumeRole(sourceAWS, input, sourceAWS.AssumeRole)
+	if err != nil {
+		return nil, fmt.Errorf("error trying to AssumeRole %s via %s: %v", roleARN, chain[len(chain)-2], err)
+	}
+	profile.Expires = creds.Expires
+
+	if err := app.save(profileName, profile, creds); err != nil {
+		return nil, err
+	}
+
+	return creds, nil
+}
+
+// assumeRoleWithIdentityProvider assumes a single role using a web identity
+// token (e.g. an OIDC token from a CI system) or a SAML assertion instead of
+// the current IAM principal. The token or assertion is read every time new
+// credentials are needed, as they are usually short-lived. Session tags,
+// external IDs and source identities can't be set this way, so they only
+// apply to roles assumed from this one.
+func (app *App) assumeRoleWithIdentityProvider(options AssumeRoleParameters) (*TemporaryCredentials, error) {
+	input, err := app.assumeRoleInput(options)
+	if err != nil {
+		return nil, err
+	}
+	input.Tags = nil
+	input.TransitiveTagKeys = nil
+	input.ExternalID = ""
+	input.SourceIdentity = ""
+
+	profileName, err := app.profileName(options.UserRole, app.sessionHash(input, "", nil))
+	if err != nil {
+		return nil, err
+	}
+
+	roleARN, err := app.roleARN(options.UserRole)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.validateDuration(input.Duration); err != nil {
+		return nil, err
+	}
+
+	profile, err := app.awsConfig.GetProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		profile = &ProfileConfiguration{}
+	}
+
+	if !app.credentialsExpired(profile.Expires) && !options.ForceRefresh {
+		return app.awsConfig.GetCredentials(profileName)
+	}
+
+	if app.cacheOnly {
+		return nil, errCachedCredentialsExpired
+	}
+
+	profile.RoleARN = roleARN
+	profile.MFASerial = ""
+	profile.ExternalID = ""
+	profile.Region = app.Region(options.UserRole)
+	profile.RoleAlias = app.roleAlias(options.UserRole)
+
+	input.RoleARN = roleARN
+
+	var assumeRole func(AssumeRoleInput) (*TemporaryCredentials, error)
+
+	if app.config.SAML != nil {
+		// The session name is set by the SAML identity provider
+		profile.RoleSessionName = ""
+		assumeRole, err = app.samlAssumeRole(roleARN)
+	} else {
+		profile.RoleSessionName = options.RoleSessionName
+		input.RoleSessionName = options.RoleSessionName
+		assumeRole, err = app.webIdentityAssumeRole()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// There are no credentials to call iam:GetRole with before assuming the
+	// role
+	creds, err := app.callAssumeRole(nil, input, assumeRole)
+	if err != nil {
+		return nil, fmt.Errorf("error trying to AssumeRole %s with the identity provider: %v", roleARN, err)
+	}
+	profile.Expires = creds.Expires
+
+	if err := app.save(profileName, profile, creds); err != nil {
+		return nil, err
+	}
+
+	return creds, nil
+}
+
+// webIdentityAssumeRole reads the web identity token and returns a function
+// that assumes a role with it.
+func (app *App) webIdentityAssumeRole() (func(AssumeRoleInput) (*TemporaryCredentials, error), error) {
+	token, err := app.webIdentityToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(input AssumeRoleInput) (*TemporaryCredentials, error) {
+		return app.aws.AssumeRoleWithWebIdentity(input, token)
+	}, nil
+}
+
+// samlAssumeRole reads the SAML assertion and returns a function that assumes
+// roleARN with it. The role must be one of the roles in the assertion.
+func (app *App) samlAssumeRole(roleARN string) (func(AssumeRoleInput) (*TemporaryCredentials, error), error) {
+	assertion, err := app.samlAssertion()
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := samlRoles(assertion)
+	if err != nil {
+		return nil, err
+	}
+
+	var principalARN string
+	for _, role := range roles {
+		if role.RoleARN == roleARN {
+			principalARN = role.PrincipalARN
+			break
+		}
+	}
+
+	if principalARN == "" {
+		return nil, fmt.Errorf("role %s is not in the SAML assertion; available roles:\n%s", roleARN, formatSAMLRoles(roles))
+	}
+
+	// Assertions expire after a few minutes, so a new one is read for the
+	// next role assumed with one, e.g. when refreshing credentials. Stdin
+	// can only be read once.
+	fromStdin := app.config.SAML.AssertionFile == "-"
+	if !fromStdin {
+		app.samlAssertionValue = ""
+	}
+
+	return func(input AssumeRoleInput) (*TemporaryCredentials, error) {
+		creds, err := app.aws.AssumeRoleWithSAML(input, principalARN, assertion)
+		if fromStdin && IsAWSExpiredTokenError(err) {
+			return nil, fmt.Errorf("%v; the SAML assertion can only be read from stdin once, use assertion_file or assertion_process to refresh credentials", err)
+		}
+		return creds, err
+	}, nil
+}
+
+// SAMLRoles returns the roles that can be assumed with the configured SAML
+// assertion.
+func (app *App) SAMLRoles() ([]SAMLRole, error) {
+	if app.config.SAML == nil {
+		return nil, errors.New("no SAML assertion configured")
+	}
+
+	assertion, err := app.samlAssertion()
+	if err != nil {
+		return nil, err
+	}
+
+	return samlRoles(assertion)
+}
+
+// samlAssertion reads the base64 encoded SAML assertion from the configured
+// file, stdin or command. It is kept until a role is assumed with it, so that
+// listing the roles in it and assuming one of them only prompts for a login
+// once.
+func (app *App) samlAssertion() (string, error) {
+	if app.samlAssertionValue != "" {
+		return app.samlAssertionValue, nil
+	}
+
+	saml := app.config.SAML
+
+	var assertion string
+
+	switch {
+	case saml.AssertionFile == "-":
+		if err := app.checkCanPrompt("a SAML assertion on stdin"); err != nil {
+			return "", err
+		}
+		b, err := ioutil.ReadAll(app.stdinReader)
+		if err != nil {
+			return "", fmt.Errorf("unable to read SAML assertion from stdin: %v", err)
+		}
+		assertion = string(b)
+
+	case saml.AssertionFile != "":
+		b, err := ioutil.ReadFile(saml.AssertionFile)
+		if err != nil {
+			return "", fmt.Errorf("unable to read SAML assertion: %v", err)
+		}
+		assertion = string(b)
+
+	case saml.AssertionProcess != "":
+		cmd := exec.Command("sh", "-c", saml.AssertionProcess)
+		if !app.noPrompts {
+			cmd.Stdin = app.stdin
+		}
+		cmd.Stderr = app.stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("unable to get SAML assertion from assertion_process: %v", err)
+		}
+		assertion = string(out)
+
+	default:
+		return "", errors.New("missing SAML assertion: one of assertion_file or assertion_process must be configured")
+	}
+
+	assertion = strings.TrimSpace(assertion)
+	if assertion == "" {
+		return "", errors.New("SAML assertion is empty")
+	}
+
+	app.samlAssertionValue = assertion
+
+	return assertion, nil
 }
 
-// AssumeRoleParameters are the parameters for the AssumeRole call
-type AssumeRoleParameters struct {
-	// UserRole is the ARN of the role to be assumed
-	UserRole string
+// webIdentityToken reads the web identity token from the configured file,
+// environment variable or command.
+func (app *App) webIdentityToken() (string, error) {
+	webIdentity := app.config.WebIdentity
 
-	// RoleSessionName is the session name for the AWS AssumeRole call; if it is
-	// the empty string, the current username will be used
-	RoleSessionName string
+	var token string
 
-	// When ForceRefresh is true, assumerole will bypass the local cache and do a
-	// call to sts:AssumeRole to retrieve fresh credentials.
-	ForceRefresh bool
-}
+	switch {
+	case webIdentity.TokenFile != "":
+		b, err := ioutil.ReadFile(webIdentity.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("unable to read web identity token: %v", err)
+		}
+		token = string(b)
 
-// used here and in tests
-var errAssumedRoleNeedsSessionName = errors.New("Validation error: missing role session name when current IAM principal is an assumed role")
+	case webIdentity.TokenEnv != "":
+		token = os.Getenv(webIdentity.TokenEnv)
 
-// NewApp creates a new App.
-func NewApp(opts ...Option) (*App, error) {
-	app := &App{
-		stdin:  os.Stdin,
-		stderr: os.Stderr,
-	}
+	case webIdentity.TokenProcess != "":
+		cmd := exec.Command("sh", "-c", webIdentity.TokenProcess)
+		cmd.Stderr = app.stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("unable to get web identity token from token_process: %v", err)
+		}
+		token = string(out)
 
-	if err := app.applyOptions(opts...); err != nil {
-		return nil, err
+	default:
+		return "", errors.New("missing web identity token: one of token_file, token_env or token_process must be configured")
 	}
 
-	if err := app.setDefaults(); err != nil {
-		return nil, err
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", errors.New("web identity token is empty")
 	}
 
-	app.stdinReader = bufio.NewReader(app.stdin)
-
-	return app, nil
+	return token, nil
 }
 
-// AssumeRole takes a role name and calls AWS AssumeRole, returning a
-// set of temporary credentials. If MFA is required, it will prompt for
-// an MFA token interactively.
-func (app *App) AssumeRole(options AssumeRoleParameters) (*TemporaryCredentials, error) {
-	profileName, err := app.profileName(options.UserRole)
-	if err != nil {
-		return nil, err
-	}
+// callAssumeRole calls assumeRole, which is one of the AWSProvider's methods
+// for assuming a role. If the requested duration exceeds the
+// MaxSessionDuration of the role, the call is retried with the limit looked up
+// with iam:GetRole through aws (unless it is nil), or else with the limit from
+// the error if AWS gives it, or else with a duration one hour shorter each
+// time, until it succeeds or the duration reaches the one hour that every role
+// allows. The duration that worked is remembered for the role, so refreshes
+// don't go through the same failed calls again.
+func (app *App) callAssumeRole(aws AWSProvider, input AssumeRoleInput, assumeRole func(AssumeRoleInput) (*TemporaryCredentials, error)) (*TemporaryCredentials, error) {
+	requestedDuration := input.Duration
 
-	profile, err := app.awsConfig.GetProfile(profileName)
-	if err != nil {
-		return nil, err
-	}
-	if profile == nil {
-		profile = &ProfileConfiguration{}
+	if maxDuration, ok := app.roleMaxDurations[input.RoleARN]; ok && input.Duration > maxDuration {
+		input.Duration = maxDuration
 	}
 
-	currentPrincipalIsAssumedRole, err := app.CurrentPrincipalIsAssumedRole()
-	if err != nil {
-		return nil, fmt.Errorf("unable to check IAM principal type: %v", err)
+	for {
+		creds, err := assumeRole(input)
+
+		if IsAWSMaxSessionDurationError(err) && input.Duration > minRoleMaxDuration {
+			maxDuration, ok := app.roleMaxSessionDuration(aws, input.RoleARN, err)
+			if !ok || maxDuration >= input.Duration {
+				maxDuration = shorterSessionDuration(input.Duration)
+			}
+			if maxDuration < minRoleMaxDuration {
+				maxDuration = minRoleMaxDuration
+			}
+			input.Duration = maxDuration
+			continue
+		}
+
+		if IsAWSSetSourceIdentityDeniedError(err) {
+			return nil, fmt.Errorf("unable to set source identity %q: the trust policy of %s must allow sts:SetSourceIdentity: %v",
+				input.SourceIdentity, input.RoleARN, err)
+		}
+
+		if err == nil && input.Duration != requestedDuration {
+			if app.roleMaxDurations == nil {
+				app.roleMaxDurations = make(map[string]time.Duration)
+			}
+			app.roleMaxDurations[input.RoleARN] = input.Duration
+
+			fmt.Fprintf(app.stderr, "WARNING: session duration of %v exceeds the MaxSessionDuration of %s; using %v instead\n",
+				requestedDuration, input.RoleARN, input.Duration)
+		}
+
+		return creds, err
 	}
+}
 
-	// If force refresh was requested, or the credentials from a previous session
-	// are still valid return those
-	if !app.credentialsExpired(profile.Expires) && !options.ForceRefresh {
-		return app.awsConfig.GetCredentials(profileName)
+// roleMaxSessionDuration returns the MaxSessionDuration of a role, from
+// iam:GetRole if aws is allowed to call it for the role, or otherwise from the
+// error of assuming the role with too long a duration, if it says.
+func (app *App) roleMaxSessionDuration(aws AWSProvider, roleARN string, err error) (time.Duration, bool) {
+	if aws != nil {
+		if maxDuration, err := aws.RoleMaxSessionDuration(roleARN); err == nil && maxDuration > 0 {
+			return maxDuration, true
+		}
 	}
 
-	// Get the full role ARN by combining the role prefix with the
-	// user-provided role name
-	roleARN := fmt.Sprintf("%s%s", app.config.RolePrefix, options.UserRole)
-	profile.RoleARN = roleARN
+	return maxSessionDurationFromError(err)
+}
 
-	sessionName := profile.RoleSessionName
-	if sessionName == "" {
-		if options.RoleSessionName != "" {
-			sessionName = options.RoleSessionName
-		} else {
-			if currentPrincipalIsAssumedRole {
-				return nil, errAssumedRoleNeedsSessionName
-			}
-			sessionName, err = app.aws.Username()
-			if err != nil {
-				return nil, fmt.Errorf("unable to get username from AWS: %v", err)
-			}
+// CurrentPrincipalIsAssumedRole returns true is the current principal is an assumed role.
+func (app *App) CurrentPrincipalIsAssumedRole() (bool, error) {
+	arn, err := app.aws.CurrentPrincipalARN()
+	if err != nil {
+		return false, err
+	}
+	return isAssumedRoleARN(arn), nil
+}
+
+// currentPrincipalARN returns the ARN of the current IAM principal, which is
+// only looked up once.
+func (app *App) currentPrincipalARN() (string, error) {
+	if app.principalARN == "" {
+		principalARN, err := app.aws.CurrentPrincipalARN()
+		if err != nil {
+			return "", err
 		}
-		profile.RoleSessionName = sessionName
+		app.principalARN = principalARN
 	}
 
-	// We first try to assume role without MFA and if that doesn't work then we
-	// try to assume role with MFA. Along the way, we collect errors in a
-	// multierr, so that if there is a fatal problem then we can output all
-	// errors so the user can see what happened along the way.
-	var finalErr error
+	return app.principalARN, nil
+}
 
-	// Try to assume role without MFA
-	creds, err := app.aws.AssumeRole(roleARN, sessionName)
+// principalIsAssumedRole returns whether the current IAM principal is an
+// assumed role, like CurrentPrincipalIsAssumedRole, but only looks it up once.
+func (app *App) principalIsAssumedRole() (bool, error) {
+	principalARN, err := app.currentPrincipalARN()
 	if err != nil {
-		if IsAWSAccessDeniedError(err) {
-			finalErr = multierror.Append(finalErr, fmt.Errorf("error trying to AssumeRole without MFA: %v", err))
-		} else {
-			// Fail immediately if the error was something other than "access denied"
-			return nil, err
+		return false, err
+	}
+	return isAssumedRoleARN(principalARN), nil
+}
+
+// currentUsername returns the username of the current IAM user, which is only
+// looked up once.
+func (app *App) currentUsername() (string, error) {
+	if app.username == "" {
+		username, err := app.aws.Username()
+		if err != nil {
+			return "", err
 		}
+		app.username = username
 	}
-	if creds != nil {
-		profile.Expires = creds.Expires
 
-		// Save credentials
-		if err := app.save(profileName, profile, creds); err != nil {
-			return nil, err
+	return app.username, nil
+}
+
+// defaultSessionName returns the session name to use when none is given,
+// which is the username of the current IAM user, or the configured session
+// name when using an identity provider.
+func (app *App) defaultSessionName() (string, error) {
+	// There's no IAM user when using an identity provider
+	if app.config.usesIdentityProvider() {
+		if sessionName := app.config.identityProviderSessionName(); sessionName != "" {
+			return sessionName, nil
 		}
+		return defaultIdentityProviderSessionName, nil
+	}
 
-		return creds, nil
+	currentPrincipalIsAssumedRole, err := app.principalIsAssumedRole()
+	if err != nil {
+		return "", fmt.Errorf("unable to check IAM principal type: %v", err)
 	}
 
 	if currentPrincipalIsAssumedRole {
-		// assumed roles don't have an user name or MFA device associated with them
-		return nil, finalErr
+		return "", errAssumedRoleNeedsSessionName
 	}
 
-	// Get user's MFA device
-	mfaDeviceARN, err := app.mfaDevice()
+	sessionName, err := app.currentUsername()
 	if err != nil {
-		finalErr = multierror.Append(finalErr, fmt.Errorf("error trying to AssumeRole with MFA: %v", err))
-		return nil, finalErr
+		return "", fmt.Errorf("unable to get username from AWS: %v", err)
 	}
-	profile.MFASerial = mfaDeviceARN
 
-	// Get token
-	mfaToken, err := app.mfaToken()
-	if err != nil {
-		finalErr = multierror.Append(finalErr, fmt.Errorf("error trying to AssumeRole with MFA: %v", err))
-		return nil, finalErr
+	return sessionName, nil
+}
+
+// sourceIdentity returns the source identity to set on sessions: the one from
+// the configuration, or otherwise the username of the current IAM user unless
+// set_source_identity is disabled. When the current IAM principal is an
+// assumed role, AWS keeps the source identity of its session for the new one
+// and rejects a different one, so none is set.
+func (app *App) sourceIdentity() (string, error) {
+	// There's no IAM user when using an identity provider; the configured
+	// source identity is set on the roles assumed from its role
+	if app.config.usesIdentityProvider() {
+		return app.config.SourceIdentity, nil
 	}
 
-	// Assume role
-	creds, err = app.aws.AssumeRoleWithMFA(roleARN, sessionName, mfaDeviceARN, mfaToken)
+	currentPrincipalIsAssumedRole, err := app.principalIsAssumedRole()
 	if err != nil {
-		finalErr = multierror.Append(finalErr, fmt.Errorf("error trying to AssumeRole with MFA: %v; giving up", err))
-		return nil, finalErr
+		return "", fmt.Errorf("unable to check IAM principal type: %v", err)
 	}
-	profile.Expires = creds.Expires
 
-	// Save credentials
-	if err := app.save(profileName, profile, creds); err != nil {
-		return nil, err
+	if currentPrincipalIsAssumedRole {
+		return "", nil
 	}
 
-	return creds, nil
-}
+	if app.config.SourceIdentity != "" {
+		return app.config.SourceIdentity, nil
+	}
 
-// CurrentPrincipalIsAssumedRole returns true is the current principal is an assumed role.
-func (app *App) CurrentPrincipalIsAssumedRole() (bool, error) {
-	arn, err := app.aws.CurrentPrincipalARN()
+	if !app.config.setSourceIdentity() {
+		return "", nil
+	}
+
+	username, err := app.currentUsername()
 	if err != nil {
-		return false, err
+		return "", fmt.Errorf("unable to get username from AWS for the source identity: %v", err)
 	}
-	return regexp.MatchString(`^arn:aws:sts::[0-9]+:assumed-role/`, arn)
+
+	return username, nil
 }
 
 // credentialsExpired returns a boolean indicating whether the credentials
@@ -212,6 +1115,195 @@ func (app *App) credentialsExpired(expiryTime time.Time) bool {
 	return app.clock.Now().After(expiryTime.Add(-app.config.RefreshBeforeExpiry))
 }
 
+// sessionDuration returns the lifetime to request for the credentials, in
+// order of precedence: the parameters, the role's configuration, and then the
+// top-level configuration.
+func (app *App) sessionDuration(options AssumeRoleParameters) time.Duration {
+	if options.Duration != 0 {
+		return options.Duration
+	}
+
+	if roleConfig, ok := app.config.Roles[options.UserRole]; ok && roleConfig.Duration != 0 {
+		return roleConfig.Duration
+	}
+
+	return app.config.Duration
+}
+
+// assumeRoleInput returns the settings for the sts:AssumeRole call for the
+// role, apart from the role ARN and session name.
+func (app *App) assumeRoleInput(options AssumeRoleParameters) (AssumeRoleInput, error) {
+	input := AssumeRoleInput{
+		Duration:       app.sessionDuration(options),
+		ExternalID:     options.ExternalID,
+		SourceIdentity: options.SourceIdentity,
+	}
+
+	if input.ExternalID == "" {
+		input.ExternalID = app.config.Roles[options.UserRole].ExternalID
+	}
+
+	var err error
+
+	if input.Policy, input.PolicyARNs, err = app.sessionPolicy(options); err != nil {
+		return input, err
+	}
+
+	if input.Tags, input.TransitiveTagKeys, err = app.sessionTags(options); err != nil {
+		return input, err
+	}
+
+	return input, nil
+}
+
+// sessionPolicy returns the session policy for the role, with the inline
+// policy and the managed policy ARNs each taken from the parameters or
+// otherwise from the role's configuration.
+func (app *App) sessionPolicy(options AssumeRoleParameters) (policy string, policyARNs []string, err error) {
+	roleConfig := app.config.Roles[options.UserRole]
+
+	document := options.Policy
+	if document == "" && len(roleConfig.Policy) > 0 {
+		if document, err = policyDocument(roleConfig.Policy); err != nil {
+			return "", nil, fmt.Errorf("invalid session policy for role %s: %v", options.UserRole, err)
+		}
+	}
+
+	if document != "" {
+		compacted := &bytes.Buffer{}
+		if err := json.Compact(compacted, []byte(document)); err != nil {
+			return "", nil, fmt.Errorf("invalid session policy: %v", err)
+		}
+		policy = compacted.String()
+	}
+
+	policyARNs = options.PolicyARNs
+	if len(policyARNs) == 0 {
+		policyARNs = roleConfig.PolicyARNs
+	}
+
+	for _, policyARN := range policyARNs {
+		if !isValidARN(policyARN) {
+			return "", nil, fmt.Errorf("invalid session policy ARN: %v", policyARN)
+		}
+	}
+
+	return policy, policyARNs, nil
+}
+
+// sessionTags returns the session tags for the role, merged from the context
+// of the project (if enabled), the top-level configuration, the role's
+// configuration and the parameters, with later ones taking precedence.
+func (app *App) sessionTags(options AssumeRoleParameters) (tags map[string]string, transitiveTagKeys []string, err error) {
+	roleConfig := app.config.Roles[options.UserRole]
+
+	var sources []map[string]string
+	if app.config.ContextTags {
+		sources = append(sources, app.contextTags())
+	}
+	sources = append(sources, app.config.Tags, roleConfig.Tags, options.Tags)
+
+	for _, source := range sources {
+		for key, value := range source {
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[key] = value
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, keys := range [][]string{app.config.TransitiveTagKeys, roleConfig.TransitiveTagKeys, options.TransitiveTagKeys} {
+		for _, key := range keys {
+			if _, ok := tags[key]; !ok {
+				return nil, nil, fmt.Errorf("invalid transitive tag key %q: no session tag with that key", key)
+			}
+			if !seen[key] {
+				seen[key] = true
+				transitiveTagKeys = append(transitiveTagKeys, key)
+			}
+		}
+	}
+	sort.Strings(transitiveTagKeys)
+
+	return tags, transitiveTagKeys, nil
+}
+
+// sessionHash returns the hash that the cached credentials of the session are
+// kept apart by, from the package-level sessionHash in utils.go. The commit from the context tags is left
+// out, so that a session is reused across commits rather than cached again
+// for every one; it records the commit the session was created at.
+func (app *App) sessionHash(input AssumeRoleInput, source string, via []string) string {
+	if app.config.ContextTags {
+		if commit, ok := app.contextTags()[gitCommitTagKey]; ok && input.Tags[gitCommitTagKey] == commit {
+			input.Tags, input.TransitiveTagKeys = withoutTags(input.Tags, input.TransitiveTagKeys, []string{gitCommitTagKey})
+		}
+	}
+
+	return sessionHash(input, source, via)
+}
+
+// credentialsSource returns where the credentials that roles are assumed with
+// come from: the one given with WithCredentialsSource, e.g. by the agent that
+// calls AWS, or else this process's, as returned by CredentialsSource.
+func (app *App) credentialsSource() string {
+	if app.credentialsSourceOverride != nil {
+		return *app.credentialsSourceOverride
+	}
+
+	return CredentialsSource(&app.config)
+}
+
+// CredentialsSource returns where the credentials that roles are assumed with
+// come from for config, in the order the AWS SDK looks for them: the source
+// profile, access keys in the environment, or the profile in AWS_PROFILE. It
+// is empty for the default profile, so that sessions of different principals
+// are never cached under the same profile.
+func CredentialsSource(config *Config) string {
+	if config.SourceProfile != "" {
+		return "source_profile=" + config.SourceProfile
+	}
+
+	if accessKeyID := os.Getenv("AWS_ACCESS_KEY_ID"); accessKeyID != "" {
+		return "access_key_id=" + accessKeyID
+	}
+
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = os.Getenv("AWS_DEFAULT_PROFILE")
+	}
+	if profile != "" && profile != "default" {
+		return "source_profile=" + profile
+	}
+
+	return ""
+}
+
+// contextTags returns the tags describing the project that assume-role is run
+// from, which are only looked up once.
+func (app *App) contextTags() map[string]string {
+	if app.projectTags == nil {
+		app.projectTags = contextTags(app.config.dir)
+	}
+
+	return app.projectTags
+}
+
+// validateDuration checks that the session duration is within the limits of
+// sts:AssumeRole, and that credentials with that lifetime would not be
+// considered expired straight away because of the refresh horizon.
+func (app *App) validateDuration(duration time.Duration) error {
+	if duration < minSessionDuration || duration > maxSessionDuration {
+		return fmt.Errorf("invalid session duration %v: must be between %v and %v", duration, minSessionDuration, maxSessionDuration)
+	}
+
+	if app.config.RefreshBeforeExpiry >= duration {
+		return fmt.Errorf("invalid session duration %v: must be longer than refresh_before_expiry (%v)", duration, app.config.RefreshBeforeExpiry)
+	}
+
+	return nil
+}
+
 func (app *App) mfaDevice() (string, error) {
 	devices, err := app.aws.MFADevices()
 	if err != nil {
@@ -224,6 +1316,12 @@ func (app *App) mfaDevice() (string, error) {
 		return devices[0], nil
 	}
 
+	if err := app.checkCanPrompt("an MFA device"); err != nil {
+		return "", err
+	}
+
+	app.printPromptNotice()
+
 Prompt:
 	for i, device := range devices {
 		fmt.Fprintf(app.stderr, "[%d]: %s\n", i+1, device)
@@ -250,10 +1348,32 @@ Prompt:
 	return devices[userInputInt-1], nil
 }
 
+// checkCanPrompt returns an error if the user can't be prompted for input,
+// because a command that assume-role runs has the terminal.
+func (app *App) checkCanPrompt(input string) error {
+	if app.noPrompts {
+		return &PromptRequiredError{Input: input}
+	}
+	return nil
+}
+
+// printPromptNotice prints the notice for the next prompt, if there is one.
+func (app *App) printPromptNotice() {
+	if app.promptNotice != "" {
+		fmt.Fprintf(app.stderr, "\n%s\n", app.promptNotice)
+		app.promptNotice = ""
+	}
+}
+
 func (app *App) mfaToken() (string, error) {
 	var token string
 	var err error
 
+	if err := app.checkCanPrompt("an MFA token"); err != nil {
+		return "", err
+	}
+
+	app.printPromptNotice()
 	app.stderr.Write([]byte("Enter MFA token: "))
 
 	stdinFile, ok := app.stdin.(*os.File)
@@ -274,9 +1394,64 @@ func (app *App) mfaToken() (string, error) {
 	return strings.TrimSpace(token), nil
 }
 
+// Region returns the AWS region configured for the role, or the top-level
+// region if the role has none.
+func (app *App) Region(userRole string) string {
+	if region := app.config.Roles[userRole].Region; region != "" {
+		return region
+	}
+	return app.config.Region
+}
+
+// partition returns the AWS partition (e.g. "aws", "aws-cn" or "aws-us-gov")
+// of the configured region, or of the region from the environment.
+func (app *App) partition() string {
+	region := app.config.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+
+	return partitionForRegion(region)
+}
+
+// partitionForRegion returns the AWS partition of a region, which is "aws"
+// for unknown regions.
+func partitionForRegion(region string) string {
+	if partition, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		return partition.ID()
+	}
+
+	return endpoints.AwsPartitionID
+}
+
+// iamRoleARN returns the ARN of a role in an account, in the partition of the
+// configured region.
+func (app *App) iamRoleARN(accountID string, roleName string) string {
+	return arn.ARN{
+		Partition: app.partition(),
+		Service:   "iam",
+		AccountID: accountID,
+		Resource:  "role/" + roleName,
+	}.String()
+}
+
+// roleAlias returns the name the role was given in the roles configuration,
+// or an empty string if it was given as an ARN or isn't configured.
+func (app *App) roleAlias(userRole string) string {
+	if _, ok := app.config.Roles[userRole]; !ok || isValidARN(userRole) {
+		return ""
+	}
+	return userRole
+}
+
 // profileName returns a string that will be used as the profile name
-// in the AWS config for these credentials.
-func (app *App) profileName(userRole string) (string, error) {
+// in the AWS config for these credentials. If sessionHash is not empty, it is
+// appended to the name so that sessions with session policies or tags are
+// cached separately.
+func (app *App) profileName(userRole string, sessionHash string) (string, error) {
 	var profileNamePrefix string
 
 	roleARN, err := app.roleARN(userRole)
@@ -290,12 +1465,17 @@ func (app *App) profileName(userRole string) (string, error) {
 	}
 
 	if app.config.ProfileNamePrefix != "" {
-		profileNamePrefix = app.config.ProfileNamePrefix
+		profileNamePrefix = app.expandAccount(app.config.ProfileNamePrefix, parsedARN.AccountID)
 	} else {
 		profileNamePrefix = parsedARN.AccountID
 	}
 
-	return fmt.Sprintf("%s-%s", profileNamePrefix, filepath.Base(parsedARN.Resource)), nil
+	profileName := fmt.Sprintf("%s-%s", profileNamePrefix, filepath.Base(parsedARN.Resource))
+	if sessionHash != "" {
+		profileName = fmt.Sprintf("%s-%s", profileName, sessionHash)
+	}
+
+	return profileName, nil
 }
 
 // roleARN returns the full role ARN, based on configuration and what
@@ -305,6 +1485,26 @@ func (app *App) roleARN(userRole string) (string, error) {
 		return userRole, nil
 	}
 
+	// Resolve names from the roles configuration before the prefix
+	if roleConfig, ok := app.config.Roles[userRole]; ok {
+		switch {
+		case roleConfig.ARN != "" && (roleConfig.AccountID != "" || roleConfig.RoleName != ""):
+			return "", fmt.Errorf("invalid configuration for role %s: set either arn, or account and role_name", userRole)
+
+		case roleConfig.ARN != "":
+			if !isValidARN(roleConfig.ARN) {
+				return "", fmt.Errorf("invalid role ARN for role %s: %v", userRole, roleConfig.ARN)
+			}
+			return roleConfig.ARN, nil
+
+		case roleConfig.AccountID != "" && roleConfig.RoleName != "":
+			return app.iamRoleARN(roleConfig.AccountID, roleConfig.RoleName), nil
+
+		case roleConfig.AccountID != "" || roleConfig.RoleName != "":
+			return "", fmt.Errorf("invalid configuration for role %s: account and role_name must be set together", userRole)
+		}
+	}
+
 	// Combine the user provided role name with the prefix from the
 	// config.
 	combined := fmt.Sprintf("%s%s", app.config.RolePrefix, userRole)
@@ -330,11 +1530,7 @@ func (app *App) save(profileName string, profile *ProfileConfiguration, creds *T
 
 func (app *App) setDefaults() error {
 	if app.aws == nil {
-		defaultAWS, err := NewAWS()
-		if err != nil {
-			return err
-		}
-		app.aws = defaultAWS
+		app.aws = &lazyAWS{config: &app.config}
 	}
 
 	if app.awsConfig == nil {
@@ -349,6 +1545,10 @@ func (app *App) setDefaults() error {
 		app.clock = &defaultClock{}
 	}
 
+	if app.accountsCacheFile == "" {
+		app.accountsCacheFile = filepath.Join(filepath.Dir(defaults.SharedConfigFilename()), "assume-role-accounts.json")
+	}
+
 	app.config.setDefaults()
 
 	return nil
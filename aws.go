@@ -19,13 +19,22 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/organizations"
 	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/go-ini/ini"
 )
@@ -37,13 +46,65 @@ func IsAWSAccessDeniedError(err error) bool {
 	return (ok && awsErr.Code() == "AccessDenied")
 }
 
+// IsAWSMaxSessionDurationError indicates whether an error is the AWS
+// validation error returned when the requested session duration exceeds the
+// MaxSessionDuration of the role.
+func IsAWSMaxSessionDurationError(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return (ok && awsErr.Code() == "ValidationError" && strings.Contains(awsErr.Message(), "MaxSessionDuration"))
+}
+
+var maxSessionDurationSecondsPattern = regexp.MustCompile(`MaxSessionDuration\D*?(\d+)`)
+
+// maxSessionDurationFromError returns the MaxSessionDuration of the role from
+// the message of a MaxSessionDuration validation error, if AWS included it.
+func maxSessionDurationFromError(err error) (time.Duration, bool) {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return 0, false
+	}
+
+	match := maxSessionDurationSecondsPattern.FindStringSubmatch(awsErr.Message())
+	if match == nil {
+		return 0, false
+	}
+
+	seconds, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
+
+// IsAWSExpiredTokenError indicates whether an error is the AWS error returned
+// when a web identity token or SAML assertion has expired.
+func IsAWSExpiredTokenError(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return (ok && awsErr.Code() == "ExpiredTokenException")
+}
+
+// IsAWSSetSourceIdentityDeniedError indicates whether an error is the AWS
+// "access denied" error returned when the role's trust policy doesn't allow
+// sts:SetSourceIdentity.
+func IsAWSSetSourceIdentityDeniedError(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return (ok && awsErr.Code() == "AccessDenied" && strings.Contains(awsErr.Message(), "sts:SetSourceIdentity"))
+}
+
 // AWSProvider is an interface to AWS.
 type AWSProvider interface {
-	AssumeRole(roleARN string, sessionName string) (*TemporaryCredentials, error)
-	AssumeRoleWithMFA(roleARN string, sessionName string, mfaDeviceARN string, mfaToken string) (*TemporaryCredentials, error)
+	AssumeRole(input AssumeRoleInput) (*TemporaryCredentials, error)
+	AssumeRoleWithMFA(input AssumeRoleInput, mfaDeviceARN string, mfaToken string) (*TemporaryCredentials, error)
+	AssumeRoleWithWebIdentity(input AssumeRoleInput, token string) (*TemporaryCredentials, error)
+	AssumeRoleWithSAML(input AssumeRoleInput, principalARN string, assertion string) (*TemporaryCredentials, error)
+	GetSessionToken(duration time.Duration, mfaDeviceARN string, mfaToken string) (*TemporaryCredentials, error)
+	ListAccounts() (map[string]string, error)
 	MFADevices() ([]string, error)
 	Username() (string, error)
 	CurrentPrincipalARN() (string, error)
+	RoleMaxSessionDuration(roleARN string) (time.Duration, error)
+	WithCredentials(creds *TemporaryCredentials) AWSProvider
 }
 
 // AWSConfigProvider is an interface to the AWS configuration (usually
@@ -53,6 +114,22 @@ type AWSConfigProvider interface {
 	SetCredentials(profileName string, creds *TemporaryCredentials) error
 	GetProfile(profileName string) (*ProfileConfiguration, error)
 	SetProfile(profileName string, profile *ProfileConfiguration) error
+	ListProfiles() ([]string, error)
+	DeleteProfile(profileName string) error
+	DeleteCredentials(profileName string) error
+}
+
+// AssumeRoleInput holds the parameters for a single sts:AssumeRole call.
+type AssumeRoleInput struct {
+	RoleARN           string
+	RoleSessionName   string
+	Duration          time.Duration
+	Policy            string
+	PolicyARNs        []string
+	Tags              map[string]string
+	TransitiveTagKeys []string
+	ExternalID        string
+	SourceIdentity    string
 }
 
 // ProfileConfiguration holds the configuration from a single profile
@@ -63,6 +140,16 @@ type ProfileConfiguration struct {
 	SourceProfile   string
 	RoleARN         string
 	RoleSessionName string
+	ExternalID      string
+	Region          string
+
+	// RoleAlias is the name from the roles configuration that the role was
+	// assumed with, if any.
+	RoleAlias string
+
+	// SourceIdentity is the source identity that was set on the session, if
+	// any.
+	SourceIdentity string
 }
 
 // TemporaryCredentials is a set of Amazon security credentials, along
@@ -77,36 +164,272 @@ type TemporaryCredentials struct {
 // AWS is the default implementation of AWSProvider that talks to the
 // real AWS.
 type AWS struct {
-	iam *iam.IAM
-	sts *sts.STS
+	session *session.Session
+	opts    AWSOpts
+
+	iam           *iam.IAM
+	organizations *organizations.Organizations
+	sts           *sts.STS
+}
+
+// AWSOpts are the options for talking to AWS.
+type AWSOpts struct {
+	// Profile is the profile in the shared AWS config files to get the
+	// credentials from. If it is empty, the default credential chain is used.
+	Profile string
+
+	// Region is the AWS region to use. If it is empty, the region from the
+	// environment or the shared AWS config file is used.
+	Region string
+
+	// STSRegionalEndpoint uses the STS endpoint of the region rather than the
+	// global endpoint.
+	STSRegionalEndpoint bool
+
+	// FIPS uses FIPS endpoints.
+	FIPS bool
+
+	// STSEndpointURL and IAMEndpointURL override the endpoints of STS and
+	// IAM, e.g. for VPC endpoints or local stand-ins like LocalStack.
+	STSEndpointURL string
+	IAMEndpointURL string
 }
 
 // NewAWS creates a new connection to AWS.
 func NewAWS() (AWSProvider, error) {
+	return NewAWSWithOpts(AWSOpts{})
+}
+
+// NewAWSForConfig creates a new connection to AWS with the source profile,
+// region and endpoints of the assume-role configuration.
+func NewAWSForConfig(config *Config) (AWSProvider, error) {
+	return NewAWSWithOpts(AWSOpts{
+		Profile:             config.SourceProfile,
+		Region:              config.Region,
+		STSRegionalEndpoint: config.STSRegionalEndpoint,
+		FIPS:                config.FIPS,
+		STSEndpointURL:      config.STSEndpointURL,
+		IAMEndpointURL:      config.IAMEndpointURL,
+	})
+}
+
+// lazyAWS is an AWSProvider that only creates the connection to AWS the first
+// time it is used, so that commands which only work with the cached
+// credentials don't need a working AWS configuration.
+type lazyAWS struct {
+	config *Config
+
+	once sync.Once
+	aws  AWSProvider
+	err  error
+}
+
+// provider returns the connection to AWS, creating it on the first call.
+func (l *lazyAWS) provider() (AWSProvider, error) {
+	l.once.Do(func() {
+		l.aws, l.err = NewAWSForConfig(l.config)
+	})
+	return l.aws, l.err
+}
+
+// WithCredentials returns a connection to AWS that uses the given temporary
+// credentials. If the connection can't be created, the calls made through the
+// returned provider fail with the same error.
+func (l *lazyAWS) WithCredentials(creds *TemporaryCredentials) AWSProvider {
+	p, err := l.provider()
+	if err != nil {
+		return l
+	}
+	return p.WithCredentials(creds)
+}
+
+func (l *lazyAWS) AssumeRole(input AssumeRoleInput) (*TemporaryCredentials, error) {
+	p, err := l.provider()
+	if err != nil {
+		return nil, err
+	}
+	return p.AssumeRole(input)
+}
+
+func (l *lazyAWS) AssumeRoleWithMFA(input AssumeRoleInput, mfaDeviceARN string, mfaToken string) (*TemporaryCredentials, error) {
+	p, err := l.provider()
+	if err != nil {
+		return nil, err
+	}
+	return p.AssumeRoleWithMFA(input, mfaDeviceARN, mfaToken)
+}
+
+func (l *lazyAWS) AssumeRoleWithWebIdentity(input AssumeRoleInput, token string) (*TemporaryCredentials, error) {
+	p, err := l.provider()
+	if err != nil {
+		return nil, err
+	}
+	return p.AssumeRoleWithWebIdentity(input, token)
+}
+
+func (l *lazyAWS) AssumeRoleWithSAML(input AssumeRoleInput, principalARN string, assertion string) (*TemporaryCredentials, error) {
+	p, err := l.provider()
+	if err != nil {
+		return nil, err
+	}
+	return p.AssumeRoleWithSAML(input, principalARN, assertion)
+}
+
+func (l *lazyAWS) GetSessionToken(duration time.Duration, mfaDeviceARN string, mfaToken string) (*TemporaryCredentials, error) {
+	p, err := l.provider()
+	if err != nil {
+		return nil, err
+	}
+	return p.GetSessionToken(duration, mfaDeviceARN, mfaToken)
+}
+
+func (l *lazyAWS) ListAccounts() (map[string]string, error) {
+	p, err := l.provider()
+	if err != nil {
+		return nil, err
+	}
+	return p.ListAccounts()
+}
+
+func (l *lazyAWS) MFADevices() ([]string, error) {
+	p, err := l.provider()
+	if err != nil {
+		return nil, err
+	}
+	return p.MFADevices()
+}
+
+func (l *lazyAWS) Username() (string, error) {
+	p, err := l.provider()
+	if err != nil {
+		return "", err
+	}
+	return p.Username()
+}
+
+func (l *lazyAWS) CurrentPrincipalARN() (string, error) {
+	p, err := l.provider()
+	if err != nil {
+		return "", err
+	}
+	return p.CurrentPrincipalARN()
+}
+
+func (l *lazyAWS) RoleMaxSessionDuration(roleARN string) (time.Duration, error) {
+	p, err := l.provider()
+	if err != nil {
+		return 0, err
+	}
+	return p.RoleMaxSessionDuration(roleARN)
+}
+
+// NewAWSWithOpts creates a new connection to AWS with the given region and
+// endpoints.
+func NewAWSWithOpts(opts AWSOpts) (AWSProvider, error) {
+	config := aws.Config{}
+
+	if opts.Region != "" {
+		config.Region = aws.String(opts.Region)
+	}
+
+	if opts.STSRegionalEndpoint {
+		config.STSRegionalEndpoint = endpoints.RegionalSTSEndpoint
+	}
+
+	if opts.FIPS {
+		config.UseFIPSEndpoint = endpoints.FIPSEndpointStateEnabled
+	}
+
 	session, err := session.NewSessionWithOptions(session.Options{
+		Config:            config,
+		Profile:           opts.Profile,
 		SharedConfigState: session.SharedConfigEnable,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %v", err)
 	}
+
+	return newAWS(session, opts), nil
+}
+
+// newAWS creates the clients for the AWS services from session.
+func newAWS(session *session.Session, opts AWSOpts) *AWS {
+	iamConfig := &aws.Config{}
+	if opts.IAMEndpointURL != "" {
+		iamConfig.Endpoint = aws.String(opts.IAMEndpointURL)
+	}
+
+	stsConfig := &aws.Config{}
+	if opts.STSEndpointURL != "" {
+		stsConfig.Endpoint = aws.String(opts.STSEndpointURL)
+	}
+
 	return &AWS{
-		iam: iam.New(session),
-		sts: sts.New(session),
-	}, nil
+		session:       session,
+		opts:          opts,
+		iam:           iam.New(session, iamConfig),
+		organizations: organizations.New(session),
+		sts:           sts.New(session, stsConfig),
+	}
+}
+
+// WithCredentials returns a connection to AWS that uses the given temporary
+// credentials, e.g. to assume a role from another assumed role.
+func (a *AWS) WithCredentials(creds *TemporaryCredentials) AWSProvider {
+	session := a.session.Copy(&aws.Config{
+		Credentials: credentials.NewStaticCredentials(creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken),
+	})
+
+	return newAWS(session, a.opts)
 }
 
 // AssumeRole calls sts:AssumeRole and returns temporary credentials.
-func (a *AWS) AssumeRole(roleARN string, sessionName string) (*TemporaryCredentials, error) {
-	return a.AssumeRoleWithMFA(roleARN, sessionName, "", "")
+func (a *AWS) AssumeRole(input AssumeRoleInput) (*TemporaryCredentials, error) {
+	return a.AssumeRoleWithMFA(input, "", "")
 }
 
 // AssumeRoleWithMFA calls sts:AssumeRole (with MFA information) and
 // returns temporary credentials.
-func (a *AWS) AssumeRoleWithMFA(roleARN string, sessionName string, mfaDeviceARN string, mfaToken string) (*TemporaryCredentials, error) {
+func (a *AWS) AssumeRoleWithMFA(input AssumeRoleInput, mfaDeviceARN string, mfaToken string) (*TemporaryCredentials, error) {
 	req := &sts.AssumeRoleInput{
-		DurationSeconds: aws.Int64(int64(time.Hour.Seconds())),
-		RoleArn:         aws.String(roleARN),
-		RoleSessionName: aws.String(sessionName),
+		DurationSeconds: aws.Int64(int64(input.Duration.Seconds())),
+		RoleArn:         aws.String(input.RoleARN),
+		RoleSessionName: aws.String(input.RoleSessionName),
+	}
+
+	if input.ExternalID != "" {
+		req.ExternalId = aws.String(input.ExternalID)
+	}
+
+	if input.SourceIdentity != "" {
+		req.SourceIdentity = aws.String(input.SourceIdentity)
+	}
+
+	if input.Policy != "" {
+		req.Policy = aws.String(input.Policy)
+	}
+
+	for _, policyARN := range input.PolicyARNs {
+		req.PolicyArns = append(req.PolicyArns, &sts.PolicyDescriptorType{
+			Arn: aws.String(policyARN),
+		})
+	}
+
+	tagKeys := make([]string, 0, len(input.Tags))
+	for key := range input.Tags {
+		tagKeys = append(tagKeys, key)
+	}
+	sort.Strings(tagKeys)
+
+	for _, key := range tagKeys {
+		req.Tags = append(req.Tags, &sts.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(input.Tags[key]),
+		})
+	}
+
+	if len(input.TransitiveTagKeys) > 0 {
+		req.TransitiveTagKeys = aws.StringSlice(input.TransitiveTagKeys)
 	}
 
 	if mfaDeviceARN != "" {
@@ -130,6 +453,116 @@ func (a *AWS) AssumeRoleWithMFA(roleARN string, sessionName string, mfaDeviceARN
 	}, nil
 }
 
+// AssumeRoleWithWebIdentity calls sts:AssumeRoleWithWebIdentity with a web
+// identity token and returns temporary credentials. The call isn't signed, so
+// it doesn't need any AWS credentials. Session tags, the external ID and the
+// source identity of the input are not supported by this call.
+func (a *AWS) AssumeRoleWithWebIdentity(input AssumeRoleInput, token string) (*TemporaryCredentials, error) {
+	req := &sts.AssumeRoleWithWebIdentityInput{
+		DurationSeconds:  aws.Int64(int64(input.Duration.Seconds())),
+		RoleArn:          aws.String(input.RoleARN),
+		RoleSessionName:  aws.String(input.RoleSessionName),
+		WebIdentityToken: aws.String(token),
+	}
+
+	if input.Policy != "" {
+		req.Policy = aws.String(input.Policy)
+	}
+
+	for _, policyARN := range input.PolicyARNs {
+		req.PolicyArns = append(req.PolicyArns, &sts.PolicyDescriptorType{
+			Arn: aws.String(policyARN),
+		})
+	}
+
+	res, err := a.sts.AssumeRoleWithWebIdentity(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TemporaryCredentials{
+		AccessKeyID:     *res.Credentials.AccessKeyId,
+		Expires:         *res.Credentials.Expiration,
+		SecretAccessKey: *res.Credentials.SecretAccessKey,
+		SessionToken:    *res.Credentials.SessionToken,
+	}, nil
+}
+
+// AssumeRoleWithSAML calls sts:AssumeRoleWithSAML with a base64 encoded SAML
+// assertion and returns temporary credentials. The call isn't signed, so it
+// doesn't need any AWS credentials. The session name is taken from the
+// assertion, and session tags, the external ID and the source identity of the
+// input are not supported by this call.
+func (a *AWS) AssumeRoleWithSAML(input AssumeRoleInput, principalARN string, assertion string) (*TemporaryCredentials, error) {
+	req := &sts.AssumeRoleWithSAMLInput{
+		DurationSeconds: aws.Int64(int64(input.Duration.Seconds())),
+		PrincipalArn:    aws.String(principalARN),
+		RoleArn:         aws.String(input.RoleARN),
+		SAMLAssertion:   aws.String(assertion),
+	}
+
+	if input.Policy != "" {
+		req.Policy = aws.String(input.Policy)
+	}
+
+	for _, policyARN := range input.PolicyARNs {
+		req.PolicyArns = append(req.PolicyArns, &sts.PolicyDescriptorType{
+			Arn: aws.String(policyARN),
+		})
+	}
+
+	res, err := a.sts.AssumeRoleWithSAML(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TemporaryCredentials{
+		AccessKeyID:     *res.Credentials.AccessKeyId,
+		Expires:         *res.Credentials.Expiration,
+		SecretAccessKey: *res.Credentials.SecretAccessKey,
+		SessionToken:    *res.Credentials.SessionToken,
+	}, nil
+}
+
+// GetSessionToken calls sts:GetSessionToken with MFA and returns temporary
+// credentials for the current IAM user. Roles that require MFA can be assumed
+// with these credentials without another MFA token.
+func (a *AWS) GetSessionToken(duration time.Duration, mfaDeviceARN string, mfaToken string) (*TemporaryCredentials, error) {
+	res, err := a.sts.GetSessionToken(&sts.GetSessionTokenInput{
+		DurationSeconds: aws.Int64(int64(duration.Seconds())),
+		SerialNumber:    aws.String(mfaDeviceARN),
+		TokenCode:       aws.String(mfaToken),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TemporaryCredentials{
+		AccessKeyID:     *res.Credentials.AccessKeyId,
+		Expires:         *res.Credentials.Expiration,
+		SecretAccessKey: *res.Credentials.SecretAccessKey,
+		SessionToken:    *res.Credentials.SessionToken,
+	}, nil
+}
+
+// ListAccounts lists the accounts in the AWS organization, returning a map of
+// account names to account IDs.
+func (a *AWS) ListAccounts() (map[string]string, error) {
+	accounts := make(map[string]string)
+
+	err := a.organizations.ListAccountsPages(&organizations.ListAccountsInput{}, func(page *organizations.ListAccountsOutput, lastPage bool) bool {
+		for _, account := range page.Accounts {
+			accounts[aws.StringValue(account.Name)] = aws.StringValue(account.Id)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
 // MFADevices lists the MFA devices on the current user's account.
 func (a *AWS) MFADevices() ([]string, error) {
 	username, err := a.Username()
@@ -171,6 +604,29 @@ func (a *AWS) CurrentPrincipalARN() (string, error) {
 	return *res.Arn, nil
 }
 
+// RoleMaxSessionDuration returns the MaxSessionDuration of a role with
+// iam:GetRole, which only works for roles in the account of the current
+// principal.
+func (a *AWS) RoleMaxSessionDuration(roleARN string) (time.Duration, error) {
+	parsedARN, err := arn.Parse(roleARN)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := a.iam.GetRole(&iam.GetRoleInput{
+		RoleName: aws.String(filepath.Base(parsedARN.Resource)),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(aws.Int64Value(res.Role.MaxSessionDuration)) * time.Second, nil
+}
+
+// managedKey marks the profile and credentials sections written by
+// assume-role, which are the only ones that it updates or removes.
+const managedKey = "assume_role_managed"
+
 // AWSConfig represents the default AWS config files that exist on a system at
 // ~/.aws/{config,credentials}. These two files are inherently linked for us,
 // because while the credentials are stored in the credentials file, the
@@ -282,6 +738,22 @@ func (c *AWSConfig) GetProfile(profileName string) (*ProfileConfiguration, error
 		profileConfig.RoleSessionName = key.String()
 	}
 
+	if key := section.Key("external_id"); key != nil {
+		profileConfig.ExternalID = key.String()
+	}
+
+	if key := section.Key("region"); key != nil {
+		profileConfig.Region = key.String()
+	}
+
+	if key := section.Key("role_alias"); key != nil {
+		profileConfig.RoleAlias = key.String()
+	}
+
+	if key := section.Key("source_identity"); key != nil {
+		profileConfig.SourceIdentity = key.String()
+	}
+
 	return profileConfig, nil
 }
 
@@ -293,6 +765,10 @@ func (c *AWSConfig) SetProfile(profileName string, profile *ProfileConfiguration
 		return err
 	}
 
+	if err := setIniKeyValue(section, managedKey, "true"); err != nil {
+		return err
+	}
+
 	if err := setIniKeyValue(section, "expiration", profile.Expires.Format(time.RFC3339)); err != nil {
 		return err
 	}
@@ -313,6 +789,38 @@ func (c *AWSConfig) SetProfile(profileName string, profile *ProfileConfiguration
 		return err
 	}
 
+	if profile.ExternalID != "" {
+		if err := setIniKeyValue(section, "external_id", profile.ExternalID); err != nil {
+			return err
+		}
+	} else {
+		section.DeleteKey("external_id")
+	}
+
+	if profile.Region != "" {
+		if err := setIniKeyValue(section, "region", profile.Region); err != nil {
+			return err
+		}
+	} else {
+		section.DeleteKey("region")
+	}
+
+	if profile.RoleAlias != "" {
+		if err := setIniKeyValue(section, "role_alias", profile.RoleAlias); err != nil {
+			return err
+		}
+	} else {
+		section.DeleteKey("role_alias")
+	}
+
+	if profile.SourceIdentity != "" {
+		if err := setIniKeyValue(section, "source_identity", profile.SourceIdentity); err != nil {
+			return err
+		}
+	} else {
+		section.DeleteKey("source_identity")
+	}
+
 	// Ensure dir exists
 	if err := os.MkdirAll(filepath.Dir(c.config.ConfigFilePath), 0755); err != nil {
 		return err
@@ -321,6 +829,105 @@ func (c *AWSConfig) SetProfile(profileName string, profile *ProfileConfiguration
 	return c.awsConfigIni.SaveTo(c.config.ConfigFilePath)
 }
 
+// ListProfiles returns the names of the profiles in the shared AWS config file
+// that assume-role cached credentials under, sorted by name.
+func (c *AWSConfig) ListProfiles() ([]string, error) {
+	var names []string
+
+	for _, section := range c.awsConfigIni.Sections() {
+		name := strings.TrimPrefix(section.Name(), "profile ")
+		if name == section.Name() || !c.isCachedProfile(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// isCachedProfile returns whether a profile in the shared AWS config file was
+// written by assume-role.
+func (c *AWSConfig) isCachedProfile(profileName string) bool {
+	section, err := c.awsConfigIni.GetSection(fmt.Sprintf("profile %s", profileName))
+	return err == nil && isManagedSection(section)
+}
+
+// isManagedSection returns whether an INI section carries the marker that
+// assume-role adds to every profile and credentials section it writes.
+func isManagedSection(section *ini.Section) bool {
+	return section.HasKey(managedKey) && section.Key(managedKey).MustBool(false)
+}
+
+// DeleteProfile removes a profile written by assume-role from the shared AWS
+// config file. Profiles that weren't written by assume-role are never removed.
+func (c *AWSConfig) DeleteProfile(profileName string) error {
+	sectionName := fmt.Sprintf("profile %s", profileName)
+
+	if _, err := c.awsConfigIni.GetSection(sectionName); err != nil {
+		return nil
+	}
+
+	if !c.isCachedProfile(profileName) {
+		return fmt.Errorf("profile %s was not written by assume-role", profileName)
+	}
+
+	c.awsConfigIni.DeleteSection(sectionName)
+
+	return c.awsConfigIni.SaveTo(c.config.ConfigFilePath)
+}
+
+// DeleteCredentials removes the credentials of a profile written by
+// assume-role from the AWS credential file. Credentials that weren't written by
+// assume-role are never removed.
+func (c *AWSConfig) DeleteCredentials(profileName string) error {
+	section, err := c.awsCredentialsIni.GetSection(profileName)
+	if err != nil {
+		return nil
+	}
+
+	if !isManagedSection(section) {
+		return fmt.Errorf("credentials %s were not written by assume-role", profileName)
+	}
+
+	c.awsCredentialsIni.DeleteSection(profileName)
+
+	return c.awsCredentialsIni.SaveTo(c.config.CredentialsFilePath)
+}
+
+// SetCredentialProcess configures a profile in the shared AWS config file to
+// get its credentials by running command, which must print them in the
+// credential_process format of the AWS SDKs.
+func (c *AWSConfig) SetCredentialProcess(profileName string, command string) error {
+	section, err := c.profileIniSection(profileName)
+	if err != nil {
+		return err
+	}
+
+	if err := setIniKeyValue(section, "credential_process", command); err != nil {
+		return err
+	}
+
+	// Ensure dir exists
+	if err := os.MkdirAll(filepath.Dir(c.config.ConfigFilePath), 0755); err != nil {
+		return err
+	}
+
+	return c.awsConfigIni.SaveTo(c.config.ConfigFilePath)
+}
+
+// CredentialProcess returns the credential_process command of a profile in the
+// shared AWS config file, if any.
+func (c *AWSConfig) CredentialProcess(profileName string) (string, error) {
+	section, err := c.profileIniSection(profileName)
+	if err != nil {
+		return "", err
+	}
+
+	return section.Key("credential_process").String(), nil
+}
+
 // GetCredentials retrieves the named credentials from the AWS credential file.
 func (c *AWSConfig) GetCredentials(profileName string) (*TemporaryCredentials, error) {
 	section, err := c.credentialsIniSection(profileName)
@@ -359,6 +966,10 @@ func (c *AWSConfig) SetCredentials(profileName string, creds *TemporaryCredentia
 		return err
 	}
 
+	if err := setIniKeyValue(section, managedKey, "true"); err != nil {
+		return err
+	}
+
 	if err := setIniKeyValue(section, "aws_access_key_id", creds.AccessKeyID); err != nil {
 		return err
 	}
@@ -376,6 +987,10 @@ func (c *AWSConfig) SetCredentials(profileName string, creds *TemporaryCredentia
 		return err
 	}
 
+	if err := setIniKeyValue(profile, managedKey, "true"); err != nil {
+		return err
+	}
+
 	// Set the expiry time in the profile
 	if err := setIniKeyValue(profile, "expiration", creds.Expires.Format(time.RFC3339)); err != nil {
 		return err
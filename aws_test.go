@@ -69,6 +69,10 @@ func TestWriteConfig(t *testing.T) {
 		SourceProfile:   "default",
 		RoleARN:         "arn:aws:iam::123:role/admin",
 		RoleSessionName: "",
+		ExternalID:      "vendor-external-id",
+		Region:          "eu-west-1",
+		RoleAlias:       "prod-admin",
+		SourceIdentity:  "bob",
 	}
 
 	err = awsConfig.SetProfile("test", fooTestProfile)
@@ -125,3 +129,98 @@ func TestWriteCredentialsToAWSConfigFile(t *testing.T) {
 
 	assert.Equal(t, fooCreds, fooCredsReRead)
 }
+
+func TestWriteCredentialProcess(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tempDir)
+
+	awsConfig, err := assumerole.NewAWSConfig(assumerole.AWSConfigOpts{
+		ConfigFilePath:      filepath.Join(tempDir, "aws", "config"),
+		CredentialsFilePath: filepath.Join(tempDir, "aws", "credentials"),
+	})
+	require.NoError(t, err)
+
+	command := `/usr/local/bin/assume-role --role admin --tag 'team=data "platform"' --credential-process`
+
+	err = awsConfig.SetCredentialProcess("admin", command)
+	require.NoError(t, err)
+
+	// Read the file again to make sure the command survives quoting
+	awsConfig, err = assumerole.NewAWSConfig(assumerole.AWSConfigOpts{
+		ConfigFilePath:      filepath.Join(tempDir, "aws", "config"),
+		CredentialsFilePath: filepath.Join(tempDir, "aws", "credentials"),
+	})
+	require.NoError(t, err)
+
+	reRead, err := awsConfig.CredentialProcess("admin")
+	require.NoError(t, err)
+
+	assert.Equal(t, command, reRead)
+}
+
+func TestListProfiles(t *testing.T) {
+	awsConfig, err := assumerole.NewAWSConfig(assumerole.AWSConfigOpts{
+		ConfigFilePath:      "fixtures/test-awsconfig/config",
+		CredentialsFilePath: "fixtures/test-awsconfig/credentials",
+	})
+	require.NoError(t, err)
+
+	profiles, err := awsConfig.ListProfiles()
+	require.NoError(t, err)
+
+	// Profiles without the assume_role_managed marker weren't written by
+	// assume-role, even if they have an expiration
+	assert.Equal(t, []string{"foo-test"}, profiles)
+}
+
+func TestDeleteProfileAndCredentials(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tempDir)
+
+	configFile := filepath.Join(tempDir, "config")
+	credentialsFile := filepath.Join(tempDir, "credentials")
+
+	require.NoError(t, ioutil.WriteFile(configFile, []byte("[profile hand-written]\nregion = us-east-1\nexpiration = 2018-04-23T13:45:43Z\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(credentialsFile, []byte("[hand-written]\naws_access_key_id = ABC\naws_secret_access_key = xxx\n"), 0600))
+
+	awsConfig, err := assumerole.NewAWSConfig(assumerole.AWSConfigOpts{
+		ConfigFilePath:      configFile,
+		CredentialsFilePath: credentialsFile,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, awsConfig.SetProfile("cached", &assumerole.ProfileConfiguration{
+		Expires: time.Date(2018, 4, 23, 13, 45, 43, 0, time.UTC),
+		RoleARN: "arn:aws:iam::123:role/admin",
+	}))
+	require.NoError(t, awsConfig.SetCredentials("cached", &assumerole.TemporaryCredentials{
+		AccessKeyID:     "DEF",
+		SecretAccessKey: "yyy",
+		SessionToken:    "sss",
+		Expires:         time.Date(2018, 4, 23, 13, 45, 43, 0, time.UTC),
+	}))
+
+	// Profiles that weren't written by assume-role are never removed
+	assert.Error(t, awsConfig.DeleteCredentials("hand-written"))
+	assert.Error(t, awsConfig.DeleteProfile("hand-written"))
+
+	// Either one can be removed first, and removing it again is a no-op
+	require.NoError(t, awsConfig.DeleteProfile("cached"))
+	require.NoError(t, awsConfig.DeleteProfile("cached"))
+	require.NoError(t, awsConfig.DeleteCredentials("cached"))
+	require.NoError(t, awsConfig.DeleteCredentials("cached"))
+
+	config, err := ioutil.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.NotContains(t, string(config), "cached")
+	assert.Contains(t, string(config), "[profile hand-written]")
+
+	credentials, err := ioutil.ReadFile(credentialsFile)
+	require.NoError(t, err)
+	assert.NotContains(t, string(credentials), "cached")
+	assert.Contains(t, string(credentials), "[hand-written]")
+}
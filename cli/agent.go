@@ -0,0 +1,221 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	assumerole "github.com/uber/assume-role-cli"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// defaultAgentLifetime is how long the agent runs by default.
+const defaultAgentLifetime = 12 * time.Hour
+
+// agentClient returns a client for the agent in ASSUME_ROLE_AUTH_SOCK.
+func agentClient() (*assumerole.AgentClient, error) {
+	socketPath := os.Getenv(assumerole.AgentSocketEnv)
+	if socketPath == "" {
+		return nil, fmt.Errorf("%s is not set; start the agent with assume-role agent", assumerole.AgentSocketEnv)
+	}
+
+	return assumerole.NewAgentClient(socketPath), nil
+}
+
+// readPassphrase prompts for a passphrase, without echoing it if stdin is a
+// terminal.
+func readPassphrase(ctx *commandContext, in *bufio.Reader, prompt string) (string, error) {
+	fmt.Fprint(ctx.stderr, prompt)
+
+	if f, ok := ctx.stdin.(*os.File); ok && terminal.IsTerminal(int(f.Fd())) {
+		b, err := terminal.ReadPassword(int(f.Fd()))
+		// Echo the "enter" keypress
+		fmt.Fprintln(ctx.stderr)
+		return string(b), err
+	}
+
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("Could not read the passphrase: %v", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// listenUnix listens on a Unix socket that only the current user can connect
+// to. The socket is created with that mode, as changing it afterwards would
+// let others connect in between, e.g. to a --socket outside a private
+// directory.
+func listenUnix(socketPath string) (net.Listener, error) {
+	mask := syscall.Umask(0177)
+	defer syscall.Umask(mask)
+
+	return net.Listen("unix", socketPath)
+}
+
+// runAgent runs the agent on a Unix socket. The given command is run with
+// ASSUME_ROLE_AUTH_SOCK pointing at the socket, and the agent stops when it
+// exits; without one, the variable is printed and the agent runs until it's
+// interrupted or its lifetime is over.
+func runAgent(ctx *commandContext) int {
+	opts := ctx.opts
+
+	format := opts.format
+	if format == "" {
+		format = detectFormat(os.Getenv("SHELL"))
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	applyAWSOptions(config, opts)
+
+	aws, err := assumerole.NewAWSForConfig(config)
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	socketPath := opts.socket
+	if socketPath == "" {
+		// Like ssh-agent, the socket is in a directory that only the current
+		// user can access
+		dir, err := ioutil.TempDir("", "assume-role-agent-")
+		if err != nil {
+			return ctx.fail(err)
+		}
+		defer os.RemoveAll(dir)
+
+		socketPath = filepath.Join(dir, "agent.sock")
+	}
+
+	listener, err := listenUnix(socketPath)
+	if err != nil {
+		return ctx.fail(err)
+	}
+	defer listener.Close()
+
+	agent := assumerole.NewAgent(aws, assumerole.NewMemoryAWSConfig(), assumerole.AgentOpts{
+		Lifetime:          opts.lifetime,
+		IdleTimeout:       opts.idleTimeout,
+		CredentialsSource: assumerole.CredentialsSource(config),
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- agent.Serve(listener)
+	}()
+
+	vars := []string{fmt.Sprintf("%s=%s", assumerole.AgentSocketEnv, socketPath)}
+
+	if len(opts.args) > 0 {
+		env := append(withoutEnv(os.Environ(), []string{assumerole.AgentSocketEnv}), vars...)
+		return supervise(ctx, opts.args, env)
+	}
+
+	if err := printVars(vars, format, ctx.stdout); err != nil {
+		return ctx.fail(err)
+	}
+
+	fmt.Fprintf(ctx.stderr, "Agent listening on %s\n", socketPath)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return ctx.fail(err)
+		}
+	case <-signals:
+	}
+
+	return 0
+}
+
+// runLock locks the agent with a passphrase.
+func runLock(ctx *commandContext) int {
+	if len(ctx.opts.args) > 0 {
+		return ctx.fail(fmt.Errorf("Unknown argument: %s", ctx.opts.args[0]))
+	}
+
+	client, err := agentClient()
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	in := bufio.NewReader(ctx.stdin)
+
+	passphrase, err := readPassphrase(ctx, in, "Enter lock passphrase: ")
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	again, err := readPassphrase(ctx, in, "Again: ")
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	if passphrase != again {
+		return ctx.fail(errors.New("Passphrases do not match"))
+	}
+
+	if err := client.Lock(passphrase); err != nil {
+		return ctx.fail(err)
+	}
+
+	fmt.Fprintln(ctx.stdout, "Agent locked.")
+
+	return 0
+}
+
+// runUnlock unlocks the agent.
+func runUnlock(ctx *commandContext) int {
+	if len(ctx.opts.args) > 0 {
+		return ctx.fail(fmt.Errorf("Unknown argument: %s", ctx.opts.args[0]))
+	}
+
+	client, err := agentClient()
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	passphrase, err := readPassphrase(ctx, bufio.NewReader(ctx.stdin), "Enter lock passphrase: ")
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	if err := client.Unlock(passphrase); err != nil {
+		return ctx.fail(err)
+	}
+
+	fmt.Fprintln(ctx.stdout, "Agent unlocked.")
+
+	return 0
+}
@@ -0,0 +1,66 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	assumerole "github.com/uber/assume-role-cli"
+)
+
+func TestMainLockWithoutAgent(t *testing.T) {
+	defer setenv(assumerole.AgentSocketEnv, "")()
+
+	stderr := &bytes.Buffer{}
+
+	assert.Equal(t, 1, Main(strings.NewReader("hunter2\nhunter2\n"), &bytes.Buffer{}, stderr, []string{"lock"}))
+	assert.Equal(t, "ERROR: ASSUME_ROLE_AUTH_SOCK is not set; start the agent with assume-role agent\n", stderr.String())
+}
+
+func TestMainLockPassphrasesDoNotMatch(t *testing.T) {
+	defer setenv(assumerole.AgentSocketEnv, "/nonexistent/agent.sock")()
+
+	stderr := &bytes.Buffer{}
+
+	assert.Equal(t, 1, Main(strings.NewReader("hunter2\nhunter3\n"), &bytes.Buffer{}, stderr, []string{"lock"}))
+	assert.Equal(t, "Enter lock passphrase: Again: ERROR: Passphrases do not match\n", stderr.String())
+}
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Others can access the directory, like with a --socket in /tmp
+	require.NoError(t, os.Chmod(dir, 0777))
+
+	socketPath := filepath.Join(dir, "agent.sock")
+	listener, err := listenUnix(socketPath)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	info, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
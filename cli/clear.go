@@ -0,0 +1,92 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+)
+
+// reportRemoved prints the profiles that were removed, followed by the error
+// that stopped removing them, if any, and returns the exit code.
+func reportRemoved(ctx *commandContext, removed []string, err error) int {
+	for _, profile := range removed {
+		fmt.Fprintf(ctx.stdout, "Removed %s\n", profile)
+	}
+
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	if len(removed) == 0 {
+		fmt.Fprintf(ctx.stdout, "No cached credentials to remove\n")
+	}
+
+	return 0
+}
+
+// runClear removes the cached credentials of a role, or of every role, and the
+// cached MFA session if --mfa is given.
+func runClear(ctx *commandContext) int {
+	opts := ctx.opts
+
+	switch {
+	case len(opts.args) > 0:
+		return ctx.fail(fmt.Errorf("Unknown argument: %s", opts.args[0]))
+	case opts.all && opts.role != "":
+		return ctx.fail(errors.New("--role can't be given with --all"))
+	case !opts.all && opts.role == "" && !opts.mfa:
+		return ctx.fail(errors.New("Missing required argument: --role, --all or --mfa"))
+	}
+
+	app, err := loadConfigAndApp(ctx)
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	var removed []string
+	switch {
+	case opts.all:
+		removed, err = app.ClearAllSessions()
+	case opts.role != "":
+		removed, err = app.ClearSessions(opts.role, opts.account)
+	}
+
+	if err == nil && opts.mfa {
+		var removedMFA []string
+		removedMFA, err = app.ClearMFASessions()
+		removed = append(removed, removedMFA...)
+	}
+
+	return reportRemoved(ctx, removed, err)
+}
+
+// runPrune removes the cached credentials that have expired.
+func runPrune(ctx *commandContext) int {
+	if len(ctx.opts.args) > 0 {
+		return ctx.fail(fmt.Errorf("Unknown argument: %s", ctx.opts.args[0]))
+	}
+
+	app, err := loadConfigAndApp(ctx)
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	removed, err := app.PruneSessions()
+
+	return reportRemoved(ctx, removed, err)
+}
@@ -20,8 +20,10 @@ package cli
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"strings"
 	"syscall"
 
 	assumerole "github.com/uber/assume-role-cli"
@@ -52,93 +54,259 @@ func execute(cmd string, args []string, env []string) error {
 	return syscall.Exec(binary, args, env)
 }
 
-func loadApp(stdin io.Reader, stdout io.Writer, stderr io.Writer) (*assumerole.App, error) {
+// loadApp returns the app for the config, which talks to AWS and keeps the
+// cache through the agent if one is running. The given options are applied
+// last.
+func loadApp(stdin io.Reader, stderr io.Writer, config *assumerole.Config, opts ...assumerole.Option) (*assumerole.App, error) {
 	appOpts := []assumerole.Option{
 		assumerole.WithStdin(stdin),
 		assumerole.WithStderr(stderr),
+		assumerole.WithConfig(config),
 	}
 
-	configFile, err := findConfigFile()
-	if err != nil {
-		return nil, err
-	}
+	if socketPath := os.Getenv(assumerole.AgentSocketEnv); socketPath != "" {
+		client := assumerole.NewAgentClient(socketPath)
 
-	if configFile != "" {
-		config, err := assumerole.LoadConfig(configFile)
+		// Roles are assumed with the agent's credentials, not the ones in
+		// this environment, so sessions are cached under its source
+		source, err := client.CredentialsSource()
 		if err != nil {
 			return nil, err
 		}
 
-		appOpts = append(appOpts, assumerole.WithConfig(config))
+		appOpts = append(appOpts,
+			assumerole.WithAWS(client),
+			assumerole.WithAWSConfig(client),
+			assumerole.WithoutAccountsCacheFile(),
+			assumerole.WithCredentialsSource(source),
+		)
 	}
 
-	return assumerole.NewApp(appOpts...)
+	return assumerole.NewApp(append(appOpts, opts...)...)
 }
 
-func printHelp(out io.Writer) {
-	fmt.Fprint(out, `Assume an AWS role and run the specified command.
+// loadConfigAndApp loads the config and the app for commands that work with
+// the cached credentials rather than assuming a role. The app only connects to
+// AWS if it needs to, e.g. to look up an account in AWS Organizations.
+func loadConfigAndApp(ctx *commandContext) (*assumerole.App, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
 
-Usage:
-  assume-role [options] <command> [args ...]
+	applyAWSOptions(config, ctx.opts)
 
-Options:
-      --help                       Help for assume-role
-      -f, --force-refresh          Forces credentials refresh irrespective of their expiry
-      --role string                Name of the role to assume
-      --role-session-name string   Name of the session for the assumed role
-`)
+	return loadApp(ctx.stdin, ctx.stderr, config)
 }
 
-func printVars(vars []string, out io.Writer) {
-	for _, x := range vars {
-		fmt.Fprintf(out, "%s\n", x)
+// applyAWSOptions overrides the source profile, region and endpoint
+// settings of the config with the options given at the command-line.
+func applyAWSOptions(config *assumerole.Config, opts *cliOpts) {
+	if opts.sourceProfile != "" {
+		config.SourceProfile = opts.sourceProfile
+	}
+
+	if opts.region != "" {
+		config.Region = opts.region
+	}
+
+	if opts.stsRegionalEndpoint {
+		config.STSRegionalEndpoint = true
+	}
+
+	if opts.fips {
+		config.FIPS = true
+	}
+
+	if opts.stsEndpointURL != "" {
+		config.STSEndpointURL = opts.stsEndpointURL
+	}
+
+	if opts.iamEndpointURL != "" {
+		config.IAMEndpointURL = opts.iamEndpointURL
+	}
+
+	if opts.federationEndpointURL != "" {
+		config.FederationEndpointURL = opts.federationEndpointURL
 	}
 }
 
-// Main is the main entry point into the CLI program.
-func Main(stdin io.Reader, stdout io.Writer, stderr io.Writer, args []string) (exitCode int) {
-	if len(args) == 1 && (args[0] == "-h" || args[0] == "--help") {
-		printHelp(stdout)
-		return 0
+// identityProviderRole returns the role to assume when none is given and
+// credentials come from an identity provider: the configured role, or the
+// only role in the SAML assertion.
+func identityProviderRole(app *assumerole.App, config *assumerole.Config) (string, error) {
+	switch {
+	case config.WebIdentity != nil && config.WebIdentity.RoleARN != "":
+		return config.WebIdentity.RoleARN, nil
+
+	case config.SAML != nil && config.SAML.RoleARN != "":
+		return config.SAML.RoleARN, nil
+
+	case config.SAML != nil:
+		roles, err := app.SAMLRoles()
+		if err != nil {
+			return "", err
+		}
+
+		if len(roles) == 1 {
+			return roles[0].RoleARN, nil
+		}
+
+		var lines []string
+		for _, role := range roles {
+			lines = append(lines, fmt.Sprintf("  %s", role.RoleARN))
+		}
+
+		return "", fmt.Errorf("%v; the SAML assertion allows these roles:\n%s", errNoRole, strings.Join(lines, "\n"))
 	}
 
-	app, err := loadApp(stdin, stdout, stderr)
+	return "", errNoRole
+}
+
+func printVars(vars []string, format string, out io.Writer) error {
+	s, err := formatVars(vars, format)
 	if err != nil {
-		fmt.Fprintf(stderr, "ERROR: %v\n", err)
-		return 1
+		return err
+	}
+
+	_, err = fmt.Fprint(out, s)
+	return err
+}
+
+// assumedRole is a role that was assumed for a command.
+type assumedRole struct {
+	app *assumerole.App
+
+	// params are the parameters the role was assumed with
+	params assumerole.AssumeRoleParameters
+
+	credentials *assumerole.TemporaryCredentials
+
+	// region is the region to run commands in, if any
+	region string
+}
+
+// env returns the environment variables to run commands with the
+// credentials.
+func (r *assumedRole) env() []string {
+	return append(credentialsToEnv(r.credentials), r.regionEnv()...)
+}
+
+// regionEnv returns the environment variables to run commands in the region
+// of the role, if it has one.
+func (r *assumedRole) regionEnv() []string {
+	if r.region == "" {
+		return nil
 	}
 
-	userOpts, err := parseOptions(args)
+	return []string{
+		fmt.Sprintf("%s=%s", "AWS_REGION", r.region),
+		fmt.Sprintf("%s=%s", "AWS_DEFAULT_REGION", r.region),
+	}
+}
+
+// assumeRole assumes the role given in opts.
+func assumeRole(ctx *commandContext, promptIn io.Reader, promptOut io.Writer) (*assumedRole, error) {
+	opts := ctx.opts
+
+	config, err := loadConfig()
 	if err != nil {
-		fmt.Fprintf(stderr, "ERROR: %v\n", err)
-		return 1
+		return nil, err
 	}
 
-	credentials, err := app.AssumeRole(assumerole.AssumeRoleParameters{
-		ForceRefresh:    userOpts.forceRefresh,
-		UserRole:        userOpts.role,
-		RoleSessionName: userOpts.roleSessionName,
-	})
+	applyAWSOptions(config, opts)
+
+	if opts.samlAssertionFile != "" {
+		if config.SAML == nil {
+			config.SAML = &assumerole.SAMLConfig{}
+		}
+		config.SAML.AssertionFile = opts.samlAssertionFile
+		config.SAML.AssertionProcess = ""
+	}
+
+	app, err := loadApp(promptIn, promptOut, config)
 	if err != nil {
-		fmt.Fprintf(stderr, "ERROR: %v\n", err)
-		return 1
+		return nil, err
 	}
 
-	vars := credentialsToEnv(credentials)
+	if opts.role == "" {
+		role, err := identityProviderRole(app, config)
+		if err != nil {
+			return nil, err
+		}
+		opts.role = role
+	}
 
-	if len(userOpts.args) == 0 {
-		// Print vars to stdout
-		printVars(vars, stdout)
-	} else {
-		// Add AWS credentials to the environment
-		env := append(os.Environ(), vars...)
+	if opts.refreshAccounts {
+		if err := app.RefreshAccounts(); err != nil {
+			return nil, err
+		}
+	}
 
-		// execve will replace the current running process on success
-		if err := execute(userOpts.args[0], userOpts.args, env); err != nil {
-			fmt.Fprintf(stderr, "ERROR: Could not execute command: %v\n", err)
-			return 127
+	var policy string
+	if opts.policyFile != "" {
+		b, err := ioutil.ReadFile(opts.policyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read policy file: %v", err)
 		}
+		policy = string(b)
 	}
 
-	return 0
+	params := assumerole.AssumeRoleParameters{
+		ForceRefresh:      opts.forceRefresh,
+		UserRole:          opts.role,
+		Account:           opts.account,
+		RoleSessionName:   opts.roleSessionName,
+		Duration:          opts.duration,
+		Via:               opts.via,
+		Policy:            policy,
+		PolicyARNs:        opts.policyARNs,
+		Tags:              opts.tags,
+		TransitiveTagKeys: opts.transitiveTagKeys,
+		ExternalID:        opts.externalID,
+	}
+
+	credentials, err := app.AssumeRole(params)
+	if err != nil {
+		return nil, err
+	}
+
+	region := app.Region(opts.role)
+	if opts.region != "" {
+		region = opts.region
+	}
+
+	return &assumedRole{
+		app:         app,
+		params:      params,
+		credentials: credentials,
+		region:      region,
+	}, nil
+}
+
+// Main is the main entry point into the CLI program.
+func Main(stdin io.Reader, stdout io.Writer, stderr io.Writer, args []string) (exitCode int) {
+	cmd, args := findCommand(args)
+
+	opts := &cliOpts{}
+	flags := commandFlags(cmd, opts)
+
+	rest, err := flags.Parse(args)
+	if err == errHelp {
+		printCommandHelp(stdout, cmd, flags)
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	opts.args = rest
+
+	return cmd.run(&commandContext{
+		stdin:   stdin,
+		stdout:  stdout,
+		stderr:  stderr,
+		opts:    opts,
+		rawArgs: args,
+	})
 }
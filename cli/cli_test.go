@@ -170,7 +170,7 @@ func TestAssumeRoleWithoutMFA(t *testing.T) {
 	}
 
 	result := execTest(t, execTestOpts{
-		args:     []string{"--role", "arn:aws:iam::675470192105:role/test_assume-role"},
+		args:     []string{"--format", "docker", "--role", "arn:aws:iam::675470192105:role/test_assume-role"},
 		testType: WITHOUT_MFA,
 	})
 	assert.Regexp(t, "^AWS_ACCESS_KEY_ID=.*\nAWS_SECRET_ACCESS_KEY=.*\nAWS_SESSION_TOKEN=.*\n$", result.Stdout.String())
@@ -209,7 +209,7 @@ func TestAssumeRoleWithMFA(t *testing.T) {
 	}
 
 	result := execTest(t, execTestOpts{
-		args:     []string{"--role", "arn:aws:iam::675470192105:role/test_assume-role"},
+		args:     []string{"--format", "docker", "--role", "arn:aws:iam::675470192105:role/test_assume-role"},
 		input:    mfa.Get() + "\n",
 		testType: WITH_MFA,
 	})
@@ -227,7 +227,7 @@ func TestCredentialsWrittenToFile(t *testing.T) {
 	defer cleanup()
 
 	result := execTest(t, execTestOpts{
-		args:     []string{"--role", "arn:aws:iam::675470192105:role/test_assume-role"},
+		args:     []string{"--format", "docker", "--role", "arn:aws:iam::675470192105:role/test_assume-role"},
 		tempDir:  tempDir,
 		testType: WITHOUT_MFA,
 	})
@@ -257,7 +257,7 @@ func TestCredentialsCached(t *testing.T) {
 
 	// Do the first AssumeRole
 	a := execTest(t, execTestOpts{
-		args:     []string{"--role", "arn:aws:iam::675470192105:role/test_assume-role"},
+		args:     []string{"--format", "docker", "--role", "arn:aws:iam::675470192105:role/test_assume-role"},
 		tempDir:  tempDir,
 		testType: WITHOUT_MFA,
 	})
@@ -266,7 +266,7 @@ func TestCredentialsCached(t *testing.T) {
 
 	// Do the second AssumeRole
 	b := execTest(t, execTestOpts{
-		args:     []string{"--role", "arn:aws:iam::675470192105:role/test_assume-role"},
+		args:     []string{"--format", "docker", "--role", "arn:aws:iam::675470192105:role/test_assume-role"},
 		tempDir:  tempDir,
 		testType: WITHOUT_MFA,
 	})
@@ -287,7 +287,7 @@ func TestCredentialsCachedForceRefresh(t *testing.T) {
 
 	// Do the first AssumeRole
 	a := execTest(t, execTestOpts{
-		args:     []string{"--role", "arn:aws:iam::675470192105:role/test_assume-role"},
+		args:     []string{"--format", "docker", "--role", "arn:aws:iam::675470192105:role/test_assume-role"},
 		tempDir:  tempDir,
 		testType: WITHOUT_MFA,
 	})
@@ -296,7 +296,7 @@ func TestCredentialsCachedForceRefresh(t *testing.T) {
 
 	// Do the second AssumeRole
 	b := execTest(t, execTestOpts{
-		args:     []string{"--force-refresh", "--role", "arn:aws:iam::675470192105:role/test_assume-role"},
+		args:     []string{"--format", "docker", "--force-refresh", "--role", "arn:aws:iam::675470192105:role/test_assume-role"},
 		tempDir:  tempDir,
 		testType: WITHOUT_MFA,
 	})
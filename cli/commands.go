@@ -0,0 +1,535 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Version is the version of assume-role.
+var Version = "1.1.0"
+
+// command is a command of assume-role, such as "assume-role exec".
+type command struct {
+	// name is the name of the command; it is empty for assume-role without
+	// a command
+	name string
+
+	// usage is the list of ways to run the command, without "assume-role"
+	usage []string
+
+	// summary is a one line description for the list of commands
+	summary string
+
+	// description is printed at the top of the command's help
+	description string
+
+	// flags adds the flags of the command, if it has any
+	flags func(f *flagSet, opts *cliOpts)
+
+	// run runs the command and returns its exit code
+	run func(ctx *commandContext) int
+}
+
+// commandContext is what a command runs with.
+type commandContext struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	// opts are the parsed flags and arguments of the command
+	opts *cliOpts
+
+	// rawArgs are the arguments of the command as they were given
+	rawArgs []string
+}
+
+// fail prints an error and returns the exit code for it.
+func (ctx *commandContext) fail(err error) int {
+	fmt.Fprintf(ctx.stderr, "ERROR: %v\n", err)
+	return 1
+}
+
+var errNoCommand = errors.New("Missing command to run")
+
+// rootCommand is assume-role without a command, which runs exec when it's
+// given a command to run, and env otherwise.
+var rootCommand *command
+
+// commands are the commands of assume-role, in the order they're listed in
+// the help.
+var commands []*command
+
+func init() {
+	rootCommand = &command{
+		usage: []string{
+			"[options] <command> [args ...]",
+			"<command> [options] [args ...]",
+		},
+		description: `Assume an AWS role and run the specified command.
+
+Without a command, the environment variables are printed in the format of
+the shell in $SHELL, to be used with e.g. eval "$(assume-role --role admin)".
+
+When AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN are set, AWS_ROLE_ARN is
+assumed with the web identity token, and --role may be omitted. With a SAML
+assertion, --role may be omitted if the assertion allows a single role.`,
+		flags: func(f *flagSet, opts *cliOpts) {
+			addEnvFlags(f, opts)
+			addSuperviseFlags(f, opts)
+		},
+		run: runDefault,
+	}
+
+	commands = []*command{
+		{
+			name:    "exec",
+			usage:   []string{"exec [options] [--] <command> [args ...]"},
+			summary: "Run a command with the credentials of a role",
+			description: `Assume an AWS role and run the specified command with its credentials.
+
+assume-role replaces itself with the command, unless --supervise is given: it
+then runs the command as a child process, passes on SIGINT, SIGTERM, SIGHUP
+and SIGQUIT to it, and exits with its exit code once it exits.
+
+With --refresh, the command is supervised and gets its credentials from a
+local container credentials endpoint instead of environment variables, like
+with serve. The AWS SDKs fetch fresh credentials from it once theirs expire,
+so that commands that run for hours keep working.`,
+			flags: func(f *flagSet, opts *cliOpts) {
+				addAssumeRoleFlags(f, opts)
+				addSuperviseFlags(f, opts)
+			},
+			run: runExec,
+		},
+		{
+			name: "env",
+			usage: []string{
+				"env [options] [--format <format>]",
+				"env --unset [--format <format>]",
+				"env [options] --credential-process",
+			},
+			summary: "Print the credentials of a role as environment variables",
+			description: `Assume an AWS role and print its credentials as environment variables, in
+the format of the shell in $SHELL unless --format is given.`,
+			flags: addEnvFlags,
+			run:   runEnv,
+		},
+		{
+			name:    "serve",
+			usage:   []string{"serve [options] [--] [<command> [args ...]]"},
+			summary: "Serve the credentials of a role on a local endpoint that refreshes them",
+			description: `Assume an AWS role and serve its credentials on a local HTTP endpoint that
+works like the ECS container credentials endpoint, refreshing them ahead of
+expiry. The command is run with AWS_CONTAINER_CREDENTIALS_FULL_URI and
+AWS_CONTAINER_AUTHORIZATION_TOKEN pointing the AWS SDKs at the endpoint, so
+that it can keep running after the role's credentials expire. With --imds,
+the endpoint works like the EC2 instance metadata service instead, for tools
+that only get credentials from it, and AWS_EC2_METADATA_SERVICE_ENDPOINT is
+set. IMDSv2 session tokens are handed out to anyone who asks, so with --imds
+every process on the machine, of any user, can get the role's credentials
+while the endpoint is served. Without a command, the variables are printed and the endpoint is served
+until assume-role is interrupted.`,
+			flags: func(f *flagSet, opts *cliOpts) {
+				addAssumeRoleFlags(f, opts)
+				addFormatFlag(f, opts)
+				f.String(&opts.listen, "listen", "address", "Loopback address to serve the credentials on (default\n"+defaultListenAddress+", a random port)")
+				f.Bool(&opts.allowRemote, "allow-remote", "Allow --listen addresses that other hosts can reach")
+				f.Bool(&opts.imds, "imds", "Serve the credentials like the EC2 instance metadata\nservice (IMDSv2) instead; any local process can get them")
+			},
+			run: runServe,
+		},
+		{
+			name:    "agent",
+			usage:   []string{"agent [options] [--] [<command> [args ...]]"},
+			summary: "Run an agent that keeps sessions and cached credentials in memory",
+			description: `Run an agent that holds the source session, the MFA session and the cached
+role credentials in memory, and serves them on a Unix socket that only the
+current user can access. When ASSUME_ROLE_AUTH_SOCK is set to the socket,
+assume-role calls AWS and keeps its cache through the agent instead of
+~/.aws. The agent's source profile, region and endpoints are used.
+
+The command is run with ASSUME_ROLE_AUTH_SOCK set, and the agent stops when
+it exits. Without a command, the variable is printed and the agent runs
+until it's interrupted, its lifetime is over or it has been idle for
+--idle-timeout.`,
+			flags: func(f *flagSet, opts *cliOpts) {
+				addAWSFlags(f, opts)
+				addFormatFlag(f, opts)
+				opts.lifetime = defaultAgentLifetime
+				f.String(&opts.socket, "socket", "path", "Path of the Unix socket (default: in a new\ntemporary directory)")
+				f.Duration(&opts.lifetime, "lifetime", "How long the agent runs, forgetting everything\nafterwards; 0 for no limit (default 12h)")
+				f.Duration(&opts.idleTimeout, "idle-timeout", "How long the agent runs without requests; 0 for\nno limit (default 0)")
+			},
+			run: runAgent,
+		},
+		{
+			name:    "lock",
+			usage:   []string{"lock"},
+			summary: "Lock the agent with a passphrase",
+			description: `Lock the agent in ASSUME_ROLE_AUTH_SOCK, which then refuses every request
+until it's unlocked with the same passphrase.`,
+			run: runLock,
+		},
+		{
+			name:        "unlock",
+			usage:       []string{"unlock"},
+			summary:     "Unlock the agent",
+			description: "Unlock the agent in ASSUME_ROLE_AUTH_SOCK with the passphrase it was locked with.",
+			run:         runUnlock,
+		},
+		{
+			name:    "list",
+			usage:   []string{"list [--output table|json]"},
+			summary: "List the cached sessions and when they expire",
+			description: `List the sessions that assume-role has cached credentials for in ~/.aws,
+with their expiry. Sessions within refresh_before_expiry of expiring are
+shown as "expiring", as they will be refreshed the next time they're used.`,
+			flags: func(f *flagSet, opts *cliOpts) {
+				f.Func("output", "string", "Output format: table or json", func(value string) error {
+					output, err := parseOutput(value)
+					if err != nil {
+						return err
+					}
+					opts.output = output
+					return nil
+				})
+			},
+			run: runList,
+		},
+		{
+			name: "clear",
+			usage: []string{
+				"clear --role <role> [--account <account>]",
+				"clear --all [--mfa]",
+				"clear --mfa",
+			},
+			summary: "Remove cached credentials",
+			description: `Remove the cached credentials of a role, or of every role, from ~/.aws.
+The cached MFA session is kept unless --mfa is given. Only profiles written
+by assume-role are removed.`,
+			flags: func(f *flagSet, opts *cliOpts) {
+				f.String(&opts.role, "role", "string", "Name or ARN of the role to remove the credentials of")
+				f.String(&opts.account, "account", "string", "Name or ID of the account of the role")
+				f.Bool(&opts.all, "all", "Remove the credentials of every role")
+				f.Bool(&opts.mfa, "mfa", "Remove the cached MFA session")
+			},
+			run: runClear,
+		},
+		{
+			name:        "prune",
+			usage:       []string{"prune"},
+			summary:     "Remove expired credentials",
+			description: "Remove the cached credentials that have expired from ~/.aws.",
+			run:         runPrune,
+		},
+		{
+			name:    "whoami",
+			usage:   []string{"whoami [options]"},
+			summary: "Show the IAM principal of the current credentials",
+			description: `Print the account, type, name and session name of the IAM principal that
+the current credentials belong to. If the credentials were cached by
+assume-role, e.g. because it set up the environment, the source identity
+and how long the credentials have left are printed too.`,
+			flags: addAWSFlags,
+			run:   runWhoami,
+		},
+		{
+			name:    "console",
+			usage:   []string{"console [options] [--open]"},
+			summary: "Sign in to the AWS console as a role",
+			description: `Assume an AWS role and print a URL that signs in to the AWS console with its
+credentials. The URL is valid for 15 minutes.`,
+			flags: func(f *flagSet, opts *cliOpts) {
+				addAssumeRoleFlags(f, opts)
+				f.String(&opts.destination, "destination", "url", "Console URL to go to after signing in (default:\nthe console home page of the region)")
+				f.Duration(&opts.consoleDuration, "session-duration", "Lifetime of the console session, between 15m and\n12h (default 12h)")
+				f.Bool(&opts.open, "open", "Open the URL in the browser instead of printing it")
+				f.String(&opts.federationEndpointURL, "federation-endpoint-url", "url", "Custom federation endpoint to get the sign-in\ntoken from")
+			},
+			run: runConsole,
+		},
+		{
+			name:    "configure-profile",
+			usage:   []string{"configure-profile --profile <name> [options]"},
+			summary: "Configure an AWS profile that gets its credentials from assume-role",
+			description: `Write a profile to ~/.aws/config that runs assume-role with the given
+options as its credential_process.`,
+			flags: func(f *flagSet, opts *cliOpts) {
+				f.String(&opts.profile, "profile", "string", "Name of the AWS profile to write")
+				addAssumeRoleFlags(f, opts)
+			},
+			run: runConfigureProfile,
+		},
+		{
+			name:        "config",
+			usage:       []string{"config"},
+			summary:     "Show the config file in use",
+			description: "Print the path and contents of the assume-role.yaml in use.",
+			run:         runConfig,
+		},
+		{
+			name:        "version",
+			usage:       []string{"version"},
+			summary:     "Print the version of assume-role",
+			description: "Print the version of assume-role.",
+			run:         runVersion,
+		},
+		{
+			name:        "help",
+			usage:       []string{"help [command]"},
+			summary:     "Help for a command",
+			description: "Print the help for assume-role or one of its commands.",
+			run:         runHelp,
+		},
+	}
+}
+
+// lookupCommand returns the command with the given name, or nil if there is
+// none.
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// findCommand returns the command named by the first argument and the
+// arguments after it, or rootCommand and all of the arguments if the first
+// argument isn't a command.
+func findCommand(args []string) (*command, []string) {
+	if len(args) > 0 {
+		if cmd := lookupCommand(args[0]); cmd != nil {
+			return cmd, args[1:]
+		}
+	}
+	return rootCommand, args
+}
+
+// commandFlags returns the flags of a command.
+func commandFlags(cmd *command, opts *cliOpts) *flagSet {
+	f := &flagSet{}
+	if cmd.flags != nil {
+		cmd.flags(f, opts)
+	}
+	return f
+}
+
+// printCommandHelp prints the help for a command.
+func printCommandHelp(out io.Writer, cmd *command, flags *flagSet) {
+	fmt.Fprintf(out, "%s\n\nUsage:\n", cmd.description)
+	for _, usage := range cmd.usage {
+		fmt.Fprintf(out, "  assume-role %s\n", usage)
+	}
+
+	if cmd == rootCommand {
+		fmt.Fprintf(out, "\nCommands:\n")
+		for _, c := range commands {
+			fmt.Fprintf(out, "  %-19s %s\n", c.name, c.summary)
+		}
+	}
+
+	fmt.Fprintf(out, "\nOptions:\n")
+	name := "assume-role"
+	if cmd.name != "" {
+		name += " " + cmd.name
+	}
+	flags.PrintDefaults(out, name)
+
+	if cmd == rootCommand {
+		fmt.Fprintf(out, "\nRun \"assume-role <command> --help\" for more information about a command.\n")
+	}
+}
+
+// runDefault runs assume-role without a command.
+func runDefault(ctx *commandContext) int {
+	opts := ctx.opts
+	if len(opts.args) > 0 && !opts.credentialProcess && !opts.unset {
+		return runExec(ctx)
+	}
+	return runEnv(ctx)
+}
+
+// runExec assumes a role and runs a command with its credentials.
+func runExec(ctx *commandContext) int {
+	if len(ctx.opts.args) == 0 {
+		return ctx.fail(errNoCommand)
+	}
+
+	role, err := assumeRole(ctx, ctx.stdin, ctx.stderr)
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	// Add AWS credentials to the environment
+	env := append(os.Environ(), role.env()...)
+
+	if ctx.opts.refresh {
+		return runRefreshing(ctx, role)
+	}
+
+	if ctx.opts.supervise {
+		return supervise(ctx, ctx.opts.args, env)
+	}
+
+	// execve will replace the current running process on success
+	if err := execute(ctx.opts.args[0], ctx.opts.args, env); err != nil {
+		fmt.Fprintf(ctx.stderr, "ERROR: Could not execute command: %v\n", err)
+		return 127
+	}
+
+	return 0
+}
+
+// runEnv assumes a role and prints its credentials.
+func runEnv(ctx *commandContext) int {
+	opts := ctx.opts
+
+	if len(opts.args) > 0 {
+		if opts.credentialProcess {
+			return ctx.fail(errors.New("A command can't be run with --credential-process"))
+		}
+		return ctx.fail(fmt.Errorf("Unknown argument: %s", opts.args[0]))
+	}
+
+	format := opts.format
+	if format == "" {
+		format = detectFormat(os.Getenv("SHELL"))
+	}
+
+	if opts.unset {
+		out, err := formatUnset(envVarNames, format)
+		if err != nil {
+			return ctx.fail(err)
+		}
+		fmt.Fprint(ctx.stdout, out)
+		return 0
+	}
+
+	// Prompts go to stderr, unless stdout is read by the AWS SDK
+	promptIn, promptOut := ctx.stdin, ctx.stderr
+
+	if opts.credentialProcess {
+		// Running assume-role as the credential_process of the profile it
+		// uses itself would recurse forever
+		if os.Getenv(credentialProcessEnv) != "" {
+			return ctx.fail(errors.New("assume-role is running as the credential_process of its own AWS profile; unset AWS_PROFILE"))
+		}
+		os.Setenv(credentialProcessEnv, "1")
+
+		if tty := openTTY(); tty != nil {
+			defer tty.Close()
+			promptIn, promptOut = tty, tty
+		}
+	}
+
+	role, err := assumeRole(ctx, promptIn, promptOut)
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	if opts.credentialProcess {
+		err = printCredentialProcess(role.credentials, ctx.stdout)
+	} else {
+		err = printVars(role.env(), format, ctx.stdout)
+	}
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	return 0
+}
+
+// runConfigureProfile writes an AWS profile that runs assume-role as its
+// credential_process.
+func runConfigureProfile(ctx *commandContext) int {
+	opts := ctx.opts
+
+	switch {
+	case opts.profile == "":
+		return ctx.fail(errNoProfile)
+	case opts.role == "":
+		return ctx.fail(errNoRole)
+	case len(opts.args) > 0:
+		return ctx.fail(fmt.Errorf("Unknown argument: %s", opts.args[0]))
+	}
+
+	if err := configureProfile(ctx.stdout, opts.profile, withoutProfile(ctx.rawArgs)); err != nil {
+		return ctx.fail(err)
+	}
+
+	return 0
+}
+
+// runConfig prints the path and contents of the config file.
+func runConfig(ctx *commandContext) int {
+	if len(ctx.opts.args) > 0 {
+		return ctx.fail(fmt.Errorf("Unknown argument: %s", ctx.opts.args[0]))
+	}
+
+	configFile, err := findConfigFile()
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	if configFile == "" {
+		fmt.Fprintf(ctx.stdout, "No assume-role.yaml found in this directory, its parents or ~/.aws\n")
+		return 0
+	}
+
+	b, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	fmt.Fprintf(ctx.stdout, "# %s\n%s", configFile, b)
+
+	return 0
+}
+
+// runVersion prints the version.
+func runVersion(ctx *commandContext) int {
+	if len(ctx.opts.args) > 0 {
+		return ctx.fail(fmt.Errorf("Unknown argument: %s", ctx.opts.args[0]))
+	}
+
+	fmt.Fprintf(ctx.stdout, "assume-role %s\n", Version)
+
+	return 0
+}
+
+// runHelp prints the help for assume-role or one of its commands.
+func runHelp(ctx *commandContext) int {
+	cmd := rootCommand
+
+	if len(ctx.opts.args) > 0 {
+		cmd = lookupCommand(ctx.opts.args[0])
+		if cmd == nil {
+			return ctx.fail(fmt.Errorf("Unknown command: %s", ctx.opts.args[0]))
+		}
+	}
+
+	printCommandHelp(ctx.stdout, cmd, commandFlags(cmd, &cliOpts{}))
+
+	return 0
+}
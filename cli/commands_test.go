@@ -0,0 +1,103 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindCommand(t *testing.T) {
+	cmd, args := findCommand([]string{"exec", "--role", "admin", "ls"})
+	assert.Equal(t, "exec", cmd.name)
+	assert.Equal(t, []string{"--role", "admin", "ls"}, args)
+
+	cmd, args = findCommand([]string{"--role", "admin", "env"})
+	assert.Equal(t, rootCommand, cmd)
+	assert.Equal(t, []string{"--role", "admin", "env"}, args)
+}
+
+func TestMainHelp(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	assert.Zero(t, Main(nil, stdout, stderr, []string{"--help"}))
+	assert.Contains(t, stdout.String(), "Commands:\n  exec ")
+	assert.Contains(t, stdout.String(), "--role string")
+
+	stdout.Reset()
+	assert.Zero(t, Main(nil, stdout, stderr, []string{"env", "-h"}))
+	assert.Contains(t, stdout.String(), "assume-role env --unset")
+	assert.Contains(t, stdout.String(), "--format string")
+	assert.NotContains(t, stdout.String(), "Commands:")
+
+	stdout.Reset()
+	assert.Zero(t, Main(nil, stdout, stderr, []string{"help", "configure-profile"}))
+	assert.Contains(t, stdout.String(), "--profile string")
+	assert.Empty(t, stderr.String())
+}
+
+func TestMainUnknownFlag(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	assert.Equal(t, 1, Main(nil, stdout, stderr, []string{"exec", "--rol", "admin", "ls"}))
+	assert.Equal(t, "ERROR: Unknown flag: --rol\n", stderr.String())
+	assert.Empty(t, stdout.String())
+}
+
+func TestMainExecWithoutCommand(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	assert.Equal(t, 1, Main(nil, stdout, stderr, []string{"exec", "--role", "admin"}))
+	assert.Equal(t, "ERROR: Missing command to run\n", stderr.String())
+}
+
+func TestMainVersion(t *testing.T) {
+	stdout := &bytes.Buffer{}
+
+	assert.Zero(t, Main(nil, stdout, &bytes.Buffer{}, []string{"version"}))
+	assert.Equal(t, "assume-role "+Version+"\n", stdout.String())
+}
+
+func TestMainEnvUnset(t *testing.T) {
+	stdout := &bytes.Buffer{}
+
+	assert.Zero(t, Main(nil, stdout, &bytes.Buffer{}, []string{"env", "--unset", "--format=fish"}))
+	assert.Contains(t, stdout.String(), "set -e AWS_SESSION_TOKEN;\n")
+}
+
+func TestMainClearRequiresRoleOrAll(t *testing.T) {
+	stderr := &bytes.Buffer{}
+
+	assert.Equal(t, 1, Main(nil, &bytes.Buffer{}, stderr, []string{"clear"}))
+	assert.Equal(t, "ERROR: Missing required argument: --role, --all or --mfa\n", stderr.String())
+
+	stderr.Reset()
+	assert.Equal(t, 1, Main(nil, &bytes.Buffer{}, stderr, []string{"clear", "--all", "--role", "admin"}))
+	assert.Equal(t, "ERROR: --role can't be given with --all\n", stderr.String())
+}
+
+func TestMainConsoleUnknownArgument(t *testing.T) {
+	stderr := &bytes.Buffer{}
+
+	assert.Equal(t, 1, Main(nil, &bytes.Buffer{}, stderr, []string{"console", "--role", "admin", "s3"}))
+	assert.Equal(t, "ERROR: Unknown argument: s3\n", stderr.String())
+}
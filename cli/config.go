@@ -20,6 +20,7 @@ import (
 	"path/filepath"
 
 	homedir "github.com/mitchellh/go-homedir"
+	assumerole "github.com/uber/assume-role-cli"
 )
 
 func fileExists(path string) bool {
@@ -66,3 +67,48 @@ func searchPaths(basePath string) (paths []string) {
 
 	return paths
 }
+
+// loadConfig loads the config file, if there is one, and detects a web
+// identity from the environment variables the AWS SDKs use for one.
+func loadConfig() (*assumerole.Config, error) {
+	config := &assumerole.Config{}
+
+	configFile, err := findConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	if configFile != "" {
+		config, err = assumerole.LoadConfig(configFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	webIdentityFromEnv(config)
+
+	return config, nil
+}
+
+// webIdentityFromEnv configures a web identity from AWS_WEB_IDENTITY_TOKEN_FILE,
+// AWS_ROLE_ARN and AWS_ROLE_SESSION_NAME, unless the config file configures a
+// web identity or SAML itself. CI systems often set these variables for other
+// tools, so they don't override what is configured.
+func webIdentityFromEnv(config *assumerole.Config) {
+	if config.WebIdentity != nil || config.SAML != nil {
+		return
+	}
+
+	tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	roleARN := os.Getenv("AWS_ROLE_ARN")
+
+	if tokenFile == "" || roleARN == "" {
+		return
+	}
+
+	config.WebIdentity = &assumerole.WebIdentityConfig{
+		RoleARN:         roleARN,
+		RoleSessionName: os.Getenv("AWS_ROLE_SESSION_NAME"),
+		TokenFile:       tokenFile,
+	}
+}
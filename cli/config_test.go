@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	assumerole "github.com/uber/assume-role-cli"
+)
+
+// setenv sets an environment variable and returns a function to restore it.
+func setenv(key string, value string) (restore func()) {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	return func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestWebIdentityFromEnv(t *testing.T) {
+	defer setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "/var/run/token")()
+	defer setenv("AWS_ROLE_ARN", "arn:aws:iam::000000000000:role/ci")()
+	defer setenv("AWS_ROLE_SESSION_NAME", "build-123")()
+
+	config := &assumerole.Config{}
+	webIdentityFromEnv(config)
+
+	assert.Equal(t, &assumerole.WebIdentityConfig{
+		RoleARN:         "arn:aws:iam::000000000000:role/ci",
+		RoleSessionName: "build-123",
+		TokenFile:       "/var/run/token",
+	}, config.WebIdentity)
+}
+
+func TestWebIdentityFromEnvConfigTakesPrecedence(t *testing.T) {
+	defer setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "/var/run/token")()
+	defer setenv("AWS_ROLE_ARN", "arn:aws:iam::000000000000:role/ci")()
+
+	config := &assumerole.Config{
+		WebIdentity: &assumerole.WebIdentityConfig{
+			TokenEnv: "CI_JOB_JWT",
+		},
+	}
+	webIdentityFromEnv(config)
+
+	assert.Equal(t, &assumerole.WebIdentityConfig{
+		TokenEnv: "CI_JOB_JWT",
+	}, config.WebIdentity)
+}
+
+func TestWebIdentityFromEnvWithSAML(t *testing.T) {
+	defer setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "/var/run/token")()
+	defer setenv("AWS_ROLE_ARN", "arn:aws:iam::000000000000:role/ci")()
+
+	config := &assumerole.Config{
+		SAML: &assumerole.SAMLConfig{
+			AssertionFile: "assertion.txt",
+		},
+	}
+	webIdentityFromEnv(config)
+
+	assert.Nil(t, config.WebIdentity)
+}
+
+func TestWebIdentityFromEnvUnset(t *testing.T) {
+	defer setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")()
+	defer setenv("AWS_ROLE_ARN", "")()
+
+	config := &assumerole.Config{}
+	webIdentityFromEnv(config)
+
+	assert.Nil(t, config.WebIdentity)
+}
@@ -0,0 +1,79 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	assumerole "github.com/uber/assume-role-cli"
+)
+
+// openBrowser opens a URL in the default browser.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	return cmd.Run()
+}
+
+// runConsole assumes a role and prints a URL that signs in to the AWS
+// console as it, or opens it in the browser with --open.
+func runConsole(ctx *commandContext) int {
+	opts := ctx.opts
+
+	if len(opts.args) > 0 {
+		return ctx.fail(fmt.Errorf("Unknown argument: %s", opts.args[0]))
+	}
+
+	role, err := assumeRole(ctx, ctx.stdin, ctx.stderr)
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	consoleURL, err := role.app.ConsoleURL(role.credentials, assumerole.ConsoleOpts{
+		Destination:     opts.destination,
+		SessionDuration: opts.consoleDuration,
+		Region:          role.region,
+	})
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	if opts.open {
+		err := openBrowser(consoleURL)
+		if err == nil {
+			return 0
+		}
+
+		// Fall back to printing the URL, e.g. on a machine without a browser
+		fmt.Fprintf(ctx.stderr, "Could not open the browser: %v\n", err)
+	}
+
+	fmt.Fprintln(ctx.stdout, consoleURL)
+
+	return 0
+}
@@ -0,0 +1,131 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	assumerole "github.com/uber/assume-role-cli"
+)
+
+// credentialProcessEnv is set while assume-role runs as a credential_process,
+// to detect it calling itself through the profile it's configured for.
+const credentialProcessEnv = "ASSUME_ROLE_CREDENTIAL_PROCESS"
+
+// credentialProcessOutput is the Version 1 credential_process document that
+// the AWS SDKs and awscli read from the command's stdout.
+type credentialProcessOutput struct {
+	Version         int
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      string
+}
+
+// printCredentialProcess prints credentials as a credential_process document.
+func printCredentialProcess(creds *assumerole.TemporaryCredentials, out io.Writer) error {
+	b, err := json.Marshal(credentialProcessOutput{
+		Version:         1,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Expiration:      creds.Expires.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(out, "%s\n", b)
+	return err
+}
+
+// openTTY opens the controlling terminal for prompts, as stdout is read by
+// the AWS SDK when running as a credential_process. It returns nil if there is
+// no terminal, e.g. in CI.
+func openTTY() *os.File {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil
+	}
+	return tty
+}
+
+var errNoProfile = errors.New("Missing required argument: --profile")
+
+// withoutProfile returns the arguments to configure-profile without its
+// --profile flag, which are the arguments for the credential_process.
+func withoutProfile(args []string) []string {
+	var out []string
+
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--profile":
+			i++
+		case strings.HasPrefix(args[i], "--profile="):
+		default:
+			out = append(out, args[i])
+		}
+	}
+
+	return out
+}
+
+// configureProfile writes a profile to the shared AWS config file that runs
+// assume-role as its credential_process with the given options.
+func configureProfile(stdout io.Writer, profile string, assumeRoleArgs []string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	command := []string{shellQuote(executable)}
+	for _, arg := range assumeRoleArgs {
+		command = append(command, shellQuote(arg))
+	}
+	command = append(command, "--credential-process")
+
+	awsConfig, err := assumerole.NewAWSConfig(assumerole.AWSConfigOpts{})
+	if err != nil {
+		return err
+	}
+
+	if err := awsConfig.SetCredentialProcess(profile, strings.Join(command, " ")); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Configured profile %s; use it with AWS_PROFILE=%s or --profile %s\n", profile, profile, profile)
+
+	return nil
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes an argument for the shell that runs the
+// credential_process command.
+func shellQuote(arg string) string {
+	if shellSafe.MatchString(arg) {
+		return arg
+	}
+
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	assumerole "github.com/uber/assume-role-cli"
+)
+
+func TestPrintCredentialProcess(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	err := printCredentialProcess(&assumerole.TemporaryCredentials{
+		AccessKeyID:     "ABC123",
+		SecretAccessKey: "supersecret",
+		SessionToken:    "123tok",
+		Expires:         time.Date(2018, 4, 23, 13, 45, 43, 0, time.UTC),
+	}, out)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"Version": 1,
+		"AccessKeyId": "ABC123",
+		"SecretAccessKey": "supersecret",
+		"SessionToken": "123tok",
+		"Expiration": "2018-04-23T13:45:43Z"
+	}`, out.String())
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "--role", shellQuote("--role"))
+	assert.Equal(t, "arn:aws:iam::123:role/admin", shellQuote("arn:aws:iam::123:role/admin"))
+	assert.Equal(t, "'team=data platform'", shellQuote("team=data platform"))
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+}
+
+func TestParseOptionsCredentialProcess(t *testing.T) {
+	opts, err := parseOptions([]string{"--role", "admin", "--credential-process"})
+	assert.NoError(t, err)
+	assert.True(t, opts.credentialProcess)
+	assert.Empty(t, opts.args)
+}
+
+func TestWithoutProfile(t *testing.T) {
+	assert.Equal(t,
+		[]string{"--role", "admin", "--region", "us-west-2"},
+		withoutProfile([]string{"--profile", "admin", "--role", "admin", "--profile=other", "--region", "us-west-2"}),
+	)
+}
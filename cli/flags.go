@@ -0,0 +1,206 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errHelp is returned by flagSet.Parse when --help is given.
+var errHelp = errors.New("help requested")
+
+// flagSet is the set of flags that a command accepts. Flags are given as
+// "--name value" or "--name=value", and boolean flags as just "--name".
+// Parsing stops at the first argument that isn't a flag, or after "--".
+type flagSet struct {
+	flags []*flagDef
+}
+
+// flagDef is a single flag of a flagSet.
+type flagDef struct {
+	// name is the name of the flag, without the leading "--"
+	name string
+
+	// short is the single letter form of the flag, if any, without the
+	// leading "-"
+	short string
+
+	// valueName describes the flag's value in help; it is empty for boolean
+	// flags, which don't take a value
+	valueName string
+
+	// usage is the help for the flag; lines after the first are indented to
+	// line up with it
+	usage string
+
+	// set parses and stores the value of the flag
+	set func(value string) error
+}
+
+// Bool adds a boolean flag that sets p.
+func (f *flagSet) Bool(p *bool, name string, usage string) *flagDef {
+	return f.add(&flagDef{
+		name:  name,
+		usage: usage,
+		set: func(value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("expected true or false, got %q", value)
+			}
+			*p = b
+			return nil
+		},
+	})
+}
+
+// String adds a flag that sets p.
+func (f *flagSet) String(p *string, name string, valueName string, usage string) *flagDef {
+	return f.Func(name, valueName, usage, func(value string) error {
+		*p = value
+		return nil
+	})
+}
+
+// StringSlice adds a flag that can be repeated, appending each value to p.
+func (f *flagSet) StringSlice(p *[]string, name string, valueName string, usage string) *flagDef {
+	return f.Func(name, valueName, usage, func(value string) error {
+		*p = append(*p, value)
+		return nil
+	})
+}
+
+// Duration adds a flag that sets p to a duration such as "1h30m".
+func (f *flagSet) Duration(p *time.Duration, name string, usage string) *flagDef {
+	return f.Func(name, "duration", usage, func(value string) error {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*p = duration
+		return nil
+	})
+}
+
+// Func adds a flag that calls set with its value.
+func (f *flagSet) Func(name string, valueName string, usage string, set func(value string) error) *flagDef {
+	return f.add(&flagDef{
+		name:      name,
+		valueName: valueName,
+		usage:     usage,
+		set:       set,
+	})
+}
+
+func (f *flagSet) add(flag *flagDef) *flagDef {
+	f.flags = append(f.flags, flag)
+	return flag
+}
+
+// lookup returns the flag for an argument such as "--name" or "-n", or nil
+// if there is none.
+func (f *flagSet) lookup(arg string) *flagDef {
+	for _, flag := range f.flags {
+		if arg == "--"+flag.name || (flag.short != "" && arg == "-"+flag.short) {
+			return flag
+		}
+	}
+	return nil
+}
+
+// Parse parses the flags at the start of args, and returns the arguments
+// after them.
+func (f *flagSet) Parse(args []string) ([]string, error) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--":
+			return args[i+1:], nil
+
+		case arg == "-h" || arg == "--help":
+			return nil, errHelp
+
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			return args[i:], nil
+		}
+
+		name, value, hasValue := arg, "", false
+		if parts := strings.SplitN(arg, "=", 2); len(parts) == 2 && strings.HasPrefix(arg, "--") {
+			name, value, hasValue = parts[0], parts[1], true
+		}
+
+		flag := f.lookup(name)
+		if flag == nil {
+			return nil, fmt.Errorf("Unknown flag: %s", name)
+		}
+
+		switch {
+		case hasValue:
+		case flag.valueName == "":
+			value = "true"
+		case i+1 < len(args):
+			i++
+			value = args[i]
+		default:
+			return nil, fmt.Errorf("Missing value for %s", name)
+		}
+
+		if err := flag.set(value); err != nil {
+			return nil, fmt.Errorf("Invalid value for %s: %v", name, err)
+		}
+	}
+
+	return nil, nil
+}
+
+// PrintDefaults prints the help for the flags, sorted by name.
+func (f *flagSet) PrintDefaults(out io.Writer, commandName string) {
+	flags := append([]*flagDef{{name: "help", short: "h", usage: "Help for " + commandName}}, f.flags...)
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+
+	var names []string
+	width := 0
+
+	for _, flag := range flags {
+		name := "    --" + flag.name
+		if flag.short != "" {
+			name = "-" + flag.short + ", --" + flag.name
+		}
+		if flag.valueName != "" {
+			name += " " + flag.valueName
+		}
+
+		names = append(names, name)
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	for i, flag := range flags {
+		lines := strings.Split(flag.usage, "\n")
+		fmt.Fprintf(out, "  %-*s   %s\n", width, names[i], lines[0])
+		for _, line := range lines[1:] {
+			fmt.Fprintf(out, "  %-*s   %s\n", width, "", line)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlagSetParse(t *testing.T) {
+	var force bool
+	var role string
+	var duration time.Duration
+	var policyARNs []string
+
+	f := &flagSet{}
+	f.Bool(&force, "force-refresh", "").short = "f"
+	f.String(&role, "role", "string", "")
+	f.Duration(&duration, "duration", "")
+	f.StringSlice(&policyARNs, "policy-arn", "string", "")
+
+	args, err := f.Parse([]string{"-f", "--role=admin", "--duration", "1h", "--policy-arn", "a", "--policy-arn=b", "ls", "-l"})
+	require.NoError(t, err)
+	assert.True(t, force)
+	assert.Equal(t, "admin", role)
+	assert.Equal(t, time.Hour, duration)
+	assert.Equal(t, []string{"a", "b"}, policyARNs)
+	assert.Equal(t, []string{"ls", "-l"}, args)
+}
+
+func TestFlagSetParseErrors(t *testing.T) {
+	var force bool
+	var role string
+
+	f := &flagSet{}
+	f.Bool(&force, "force-refresh", "")
+	f.String(&role, "role", "string", "")
+
+	_, err := f.Parse([]string{"--rol", "admin"})
+	assert.EqualError(t, err, "Unknown flag: --rol")
+
+	_, err = f.Parse([]string{"--role"})
+	assert.EqualError(t, err, "Missing value for --role")
+
+	_, err = f.Parse([]string{"--force-refresh=maybe"})
+	assert.Error(t, err)
+
+	_, err = f.Parse([]string{"--role", "admin", "--help"})
+	assert.Equal(t, errHelp, err)
+}
+
+func TestFlagSetParseDoubleDash(t *testing.T) {
+	var force bool
+
+	f := &flagSet{}
+	f.Bool(&force, "force-refresh", "")
+
+	args, err := f.Parse([]string{"--", "--force-refresh"})
+	require.NoError(t, err)
+	assert.False(t, force)
+	assert.Equal(t, []string{"--force-refresh"}, args)
+}
+
+func TestFlagSetPrintDefaults(t *testing.T) {
+	var force bool
+	var role string
+
+	f := &flagSet{}
+	f.Bool(&force, "force-refresh", "Refresh the credentials").short = "f"
+	f.String(&role, "role", "string", "Name of the role\nto assume")
+
+	out := &bytes.Buffer{}
+	f.PrintDefaults(out, "assume-role")
+
+	assert.Equal(t, `  -f, --force-refresh   Refresh the credentials
+  -h, --help            Help for assume-role
+      --role string     Name of the role
+                        to assume
+`, out.String())
+}
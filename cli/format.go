@@ -0,0 +1,179 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Output formats for the environment variables when no command is given.
+const (
+	formatBash       = "bash"
+	formatFish       = "fish"
+	formatPowerShell = "powershell"
+	formatDotenv     = "dotenv"
+	formatJSON       = "json"
+	formatDocker     = "docker"
+)
+
+// formatAliases maps the names accepted by --format to output formats.
+var formatAliases = map[string]string{
+	"bash":       formatBash,
+	"zsh":        formatBash,
+	"sh":         formatBash,
+	"fish":       formatFish,
+	"powershell": formatPowerShell,
+	"pwsh":       formatPowerShell,
+	"dotenv":     formatDotenv,
+	"json":       formatJSON,
+	"docker":     formatDocker,
+	"env-file":   formatDocker,
+}
+
+// envVarNames are the environment variables that assume-role sets, which are
+// cleared by --unset.
+var envVarNames = []string{
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_REGION",
+	"AWS_DEFAULT_REGION",
+}
+
+// parseFormat returns the output format for a --format value.
+func parseFormat(value string) (string, error) {
+	format, ok := formatAliases[strings.ToLower(value)]
+	if !ok {
+		return "", fmt.Errorf("expected one of bash, zsh, fish, powershell, dotenv, json or docker, got %q", value)
+	}
+	return format, nil
+}
+
+// detectFormat returns the output format for the shell in $SHELL. Unknown
+// shells get plain KEY=value lines, as printed by earlier versions.
+func detectFormat(shell string) string {
+	switch filepath.Base(shell) {
+	case "bash", "zsh", "sh", "ksh", "dash":
+		return formatBash
+	case "fish":
+		return formatFish
+	case "pwsh", "powershell", "pwsh.exe", "powershell.exe":
+		return formatPowerShell
+	}
+	return formatDocker
+}
+
+// formatVars formats KEY=value environment variables for the output format.
+func formatVars(vars []string, format string) (string, error) {
+	if format == formatJSON {
+		values := make(map[string]string, len(vars))
+		for _, v := range vars {
+			key, value := splitVar(v)
+			values[key] = value
+		}
+
+		b, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", nil
+	}
+
+	var out strings.Builder
+
+	for _, v := range vars {
+		key, value := splitVar(v)
+
+		switch format {
+		case formatBash:
+			fmt.Fprintf(&out, "export %s=%s\n", key, quotePOSIX(value))
+		case formatFish:
+			fmt.Fprintf(&out, "set -gx %s %s;\n", key, quoteFish(value))
+		case formatPowerShell:
+			fmt.Fprintf(&out, "$Env:%s = %s\n", key, quotePowerShell(value))
+		case formatDotenv:
+			fmt.Fprintf(&out, "%s=%s\n", key, quoteDotenv(value))
+		default:
+			// Docker env-files don't support quoting
+			fmt.Fprintf(&out, "%s=%s\n", key, value)
+		}
+	}
+
+	return out.String(), nil
+}
+
+// formatUnset formats the commands that clear the environment variables for
+// the output format, which must be a shell.
+func formatUnset(keys []string, format string) (string, error) {
+	var out strings.Builder
+
+	for _, key := range keys {
+		switch format {
+		case formatBash:
+			fmt.Fprintf(&out, "unset %s\n", key)
+		case formatFish:
+			fmt.Fprintf(&out, "set -e %s;\n", key)
+		case formatPowerShell:
+			fmt.Fprintf(&out, "Remove-Item Env:%s -ErrorAction SilentlyContinue\n", key)
+		default:
+			return "", fmt.Errorf("--unset is not supported for the %s format", format)
+		}
+	}
+
+	return out.String(), nil
+}
+
+// splitVar splits a KEY=value environment variable.
+func splitVar(v string) (key string, value string) {
+	parts := strings.SplitN(v, "=", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// quotePOSIX quotes a value for bash, zsh and other POSIX shells.
+func quotePOSIX(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// quoteFish quotes a value for fish, where backslashes and single quotes are
+// escaped inside single quotes.
+func quoteFish(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, "'", `\'`, -1)
+	return "'" + value + "'"
+}
+
+// quotePowerShell quotes a value for PowerShell, where single quotes are
+// doubled inside single quotes.
+func quotePowerShell(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+// quoteDotenv quotes a value for a .env file, if it needs quoting.
+func quoteDotenv(value string) string {
+	if shellSafe.MatchString(value) {
+		return value
+	}
+
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	return `"` + value + `"`
+}
@@ -0,0 +1,93 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testVars = []string{
+	"AWS_ACCESS_KEY_ID=ABC123",
+	"AWS_SECRET_ACCESS_KEY=it's/secret+key",
+}
+
+func TestFormatVars(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{formatBash, "export AWS_ACCESS_KEY_ID='ABC123'\nexport AWS_SECRET_ACCESS_KEY='it'\\''s/secret+key'\n"},
+		{formatFish, "set -gx AWS_ACCESS_KEY_ID 'ABC123';\nset -gx AWS_SECRET_ACCESS_KEY 'it\\'s/secret+key';\n"},
+		{formatPowerShell, "$Env:AWS_ACCESS_KEY_ID = 'ABC123'\n$Env:AWS_SECRET_ACCESS_KEY = 'it''s/secret+key'\n"},
+		{formatDotenv, "AWS_ACCESS_KEY_ID=ABC123\nAWS_SECRET_ACCESS_KEY=\"it's/secret+key\"\n"},
+		{formatDocker, "AWS_ACCESS_KEY_ID=ABC123\nAWS_SECRET_ACCESS_KEY=it's/secret+key\n"},
+	}
+
+	for _, tt := range tests {
+		out, err := formatVars(testVars, tt.format)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, out, tt.format)
+	}
+}
+
+func TestFormatVarsJSON(t *testing.T) {
+	out, err := formatVars(testVars, formatJSON)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"AWS_ACCESS_KEY_ID": "ABC123",
+		"AWS_SECRET_ACCESS_KEY": "it's/secret+key"
+	}`, out)
+}
+
+func TestFormatUnset(t *testing.T) {
+	out, err := formatUnset([]string{"AWS_REGION"}, formatBash)
+	require.NoError(t, err)
+	assert.Equal(t, "unset AWS_REGION\n", out)
+
+	out, err = formatUnset([]string{"AWS_REGION"}, formatFish)
+	require.NoError(t, err)
+	assert.Equal(t, "set -e AWS_REGION;\n", out)
+
+	_, err = formatUnset([]string{"AWS_REGION"}, formatJSON)
+	assert.Error(t, err)
+}
+
+func TestDetectFormat(t *testing.T) {
+	assert.Equal(t, formatBash, detectFormat("/bin/zsh"))
+	assert.Equal(t, formatFish, detectFormat("/usr/local/bin/fish"))
+	assert.Equal(t, formatPowerShell, detectFormat("/usr/bin/pwsh"))
+	assert.Equal(t, formatDocker, detectFormat(""))
+}
+
+func TestParseOptionsFormat(t *testing.T) {
+	opts, err := parseOptions([]string{"--role", "admin", "--format", "zsh"})
+	assert.NoError(t, err)
+	assert.Equal(t, formatBash, opts.format)
+
+	_, err = parseOptions([]string{"--role", "admin", "--format", "tcsh"})
+	assert.Error(t, err)
+}
+
+func TestParseOptionsUnset(t *testing.T) {
+	opts, err := parseOptions([]string{"--unset", "--format", "fish"})
+	assert.Equal(t, errNoRole, err)
+	assert.True(t, opts.unset)
+	assert.Equal(t, formatFish, opts.format)
+}
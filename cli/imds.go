@@ -0,0 +1,220 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	assumerole "github.com/uber/assume-role-cli"
+)
+
+// imdsEndpointEnv is the environment variable that points the AWS SDKs at an
+// instance metadata service other than the one at 169.254.169.254.
+const imdsEndpointEnv = "AWS_EC2_METADATA_SERVICE_ENDPOINT"
+
+// Headers of IMDSv2 session tokens.
+const (
+	imdsTokenHeader    = "X-aws-ec2-metadata-token"
+	imdsTokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+)
+
+// maxIMDSTokenTTL is the longest lifetime of an IMDSv2 session token.
+const maxIMDSTokenTTL = 6 * time.Hour
+
+const (
+	imdsTokenPath       = "/latest/api/token"
+	imdsCredentialsPath = "/latest/meta-data/iam/security-credentials/"
+	imdsInfoPath        = "/latest/meta-data/iam/info"
+)
+
+// imdsCredentials is the response of the security credentials path of the
+// instance metadata service.
+type imdsCredentials struct {
+	Code            string `json:"Code"`
+	LastUpdated     string `json:"LastUpdated"`
+	Type            string `json:"Type"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+}
+
+// imdsInfo is the response of the IAM info path of the instance metadata
+// service.
+type imdsInfo struct {
+	Code               string `json:"Code"`
+	LastUpdated        string `json:"LastUpdated"`
+	InstanceProfileArn string `json:"InstanceProfileArn"`
+	InstanceProfileID  string `json:"InstanceProfileId"`
+}
+
+// imdsServer emulates the IAM paths of the EC2 instance metadata service with
+// IMDSv2 session tokens, serving the credentials of a refresher as those of
+// the instance profile. Session tokens are issued without any secret, as they
+// are by the real service, so they only stop requests that were forwarded,
+// not other local processes.
+type imdsServer struct {
+	refresher *assumerole.Refresher
+
+	// roleName is the name the credentials are listed under
+	roleName string
+
+	// instanceProfileARN and instanceProfileID stand in for the instance
+	// profile of the role
+	instanceProfileARN string
+	instanceProfileID  string
+
+	mu     sync.Mutex
+	tokens map[string]time.Time
+}
+
+// newIMDSServer returns an instance metadata service for the credentials of
+// the role with the given ARN.
+func newIMDSServer(refresher *assumerole.Refresher, roleARN string) *imdsServer {
+	roleName := roleARN[strings.LastIndex(roleARN, "/")+1:]
+
+	// The ID only needs to be stable and look like one
+	sum := sha1.Sum([]byte(roleARN))
+
+	return &imdsServer{
+		refresher:          refresher,
+		roleName:           roleName,
+		instanceProfileARN: strings.Replace(roleARN, ":role/", ":instance-profile/", 1),
+		instanceProfileID:  "AIPA" + strings.ToUpper(hex.EncodeToString(sum[:]))[:17],
+		tokens:             make(map[string]time.Time),
+	}
+}
+
+func (s *imdsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == imdsTokenPath {
+		s.serveToken(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.validToken(r.Header.Get(imdsTokenHeader)) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case imdsCredentialsPath:
+		fmt.Fprint(w, s.roleName)
+	case imdsCredentialsPath + s.roleName:
+		s.serveCredentials(w)
+	case imdsInfoPath:
+		s.serveInfo(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveToken issues a session token, with the lifetime requested in the TTL
+// header.
+func (s *imdsServer) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Like the real service, refuse requests that went through a proxy
+	if r.Header.Get("X-Forwarded-For") != "" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	seconds, err := strconv.Atoi(r.Header.Get(imdsTokenTTLHeader))
+	ttl := time.Duration(seconds) * time.Second
+	if err != nil || ttl <= 0 || ttl > maxIMDSTokenTTL {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	token, err := newAuthorizationToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	for t, expires := range s.tokens {
+		if now.After(expires) {
+			delete(s.tokens, t)
+		}
+	}
+	s.tokens[token] = now.Add(ttl)
+	s.mu.Unlock()
+
+	w.Header().Set(imdsTokenTTLHeader, strconv.Itoa(seconds))
+	fmt.Fprint(w, token)
+}
+
+// validToken returns whether a session token was issued and hasn't expired.
+func (s *imdsServer) validToken(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires, ok := s.tokens[token]
+	return ok && time.Now().Before(expires)
+}
+
+func (s *imdsServer) serveCredentials(w http.ResponseWriter) {
+	credentials, err := s.refresher.Credentials()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not get credentials: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, imdsCredentials{
+		Code:            "Success",
+		LastUpdated:     time.Now().UTC().Format(time.RFC3339),
+		Type:            "AWS-HMAC",
+		AccessKeyID:     credentials.AccessKeyID,
+		SecretAccessKey: credentials.SecretAccessKey,
+		Token:           credentials.SessionToken,
+		Expiration:      credentials.Expires.UTC().Format(time.RFC3339),
+	})
+}
+
+func (s *imdsServer) serveInfo(w http.ResponseWriter) {
+	writeJSON(w, imdsInfo{
+		Code:               "Success",
+		LastUpdated:        time.Now().UTC().Format(time.RFC3339),
+		InstanceProfileArn: s.instanceProfileARN,
+		InstanceProfileID:  s.instanceProfileID,
+	})
+}
+
+// writeJSON writes a JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,105 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// imdsRequest makes a request to the instance metadata service and returns
+// the status code and body of the response.
+func imdsRequest(t *testing.T, method string, url string, headers map[string]string) (int, string) {
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp.StatusCode, string(body)
+}
+
+func TestIMDSServer(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	refresher := newTestRefresher(t, "arn:aws:iam::000000000000:role/admin", expires)
+
+	server := httptest.NewServer(newIMDSServer(refresher, "arn:aws:iam::000000000000:role/admin"))
+	defer server.Close()
+
+	// Session tokens need a TTL, and aren't given out through proxies
+	status, _ := imdsRequest(t, http.MethodPut, server.URL+imdsTokenPath, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = imdsRequest(t, http.MethodPut, server.URL+imdsTokenPath, map[string]string{
+		imdsTokenTTLHeader: "21600",
+		"X-Forwarded-For":  "10.0.0.1",
+	})
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status, token := imdsRequest(t, http.MethodPut, server.URL+imdsTokenPath, map[string]string{
+		imdsTokenTTLHeader: "21600",
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	// IMDSv1 requests without a token are refused
+	status, _ = imdsRequest(t, http.MethodGet, server.URL+imdsCredentialsPath, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	withToken := map[string]string{imdsTokenHeader: token}
+
+	status, body := imdsRequest(t, http.MethodGet, server.URL+imdsCredentialsPath, withToken)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "admin", body)
+
+	status, body = imdsRequest(t, http.MethodGet, server.URL+imdsCredentialsPath+"admin", withToken)
+	require.Equal(t, http.StatusOK, status)
+
+	var credentials imdsCredentials
+	require.NoError(t, json.Unmarshal([]byte(body), &credentials))
+	assert.Equal(t, "Success", credentials.Code)
+	assert.Equal(t, "AWS-HMAC", credentials.Type)
+	assert.Equal(t, "ABC123", credentials.AccessKeyID)
+	assert.Equal(t, "supersecret", credentials.SecretAccessKey)
+	assert.Equal(t, "123tok", credentials.Token)
+	assert.Equal(t, expires.UTC().Format(time.RFC3339), credentials.Expiration)
+
+	status, body = imdsRequest(t, http.MethodGet, server.URL+imdsInfoPath, withToken)
+	require.Equal(t, http.StatusOK, status)
+
+	var info imdsInfo
+	require.NoError(t, json.Unmarshal([]byte(body), &info))
+	assert.Equal(t, "arn:aws:iam::000000000000:instance-profile/admin", info.InstanceProfileArn)
+	assert.Len(t, info.InstanceProfileID, 21)
+
+	status, _ = imdsRequest(t, http.MethodGet, server.URL+imdsCredentialsPath+"other", withToken)
+	assert.Equal(t, http.StatusNotFound, status)
+}
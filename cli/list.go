@@ -0,0 +1,177 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	assumerole "github.com/uber/assume-role-cli"
+)
+
+// Output formats of the list command.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// parseOutput returns the output format for an --output value.
+func parseOutput(value string) (string, error) {
+	switch value {
+	case outputTable, outputJSON:
+		return value, nil
+	}
+	return "", fmt.Errorf("expected table or json, got %q", value)
+}
+
+// Statuses of cached sessions.
+const (
+	statusValid    = "valid"
+	statusExpiring = "expiring"
+	statusExpired  = "expired"
+)
+
+// sessionStatus returns the status of a cached session. Sessions within
+// refresh_before_expiry of expiring are "expiring", as they will be refreshed
+// the next time they are used.
+func sessionStatus(session assumerole.CachedSession) string {
+	switch {
+	case session.Remaining <= 0:
+		return statusExpired
+	case session.NeedsRefresh:
+		return statusExpiring
+	}
+	return statusValid
+}
+
+// formatRemaining formats the time left until credentials expire.
+func formatRemaining(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+
+	d = d.Truncate(time.Minute)
+	if d < time.Minute {
+		return "<1m"
+	}
+
+	hours, minutes := d/time.Hour, (d%time.Hour)/time.Minute
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh%02dm", hours, minutes)
+}
+
+// orDash returns "-" for empty values in tables.
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// sessionJSON is a cached session in the JSON output of list.
+type sessionJSON struct {
+	Profile          string `json:"profile"`
+	RoleARN          string `json:"role_arn"`
+	RoleSessionName  string `json:"role_session_name"`
+	MFASerial        string `json:"mfa_serial,omitempty"`
+	SourceProfile    string `json:"source_profile,omitempty"`
+	Expiration       string `json:"expiration"`
+	RemainingSeconds int64  `json:"remaining_seconds"`
+	Status           string `json:"status"`
+}
+
+// printSessions prints cached sessions as a table or as JSON.
+func printSessions(sessions []assumerole.CachedSession, output string, out io.Writer) error {
+	if output == outputJSON {
+		values := []sessionJSON{}
+		for _, session := range sessions {
+			remaining := int64(session.Remaining / time.Second)
+			if remaining < 0 {
+				remaining = 0
+			}
+
+			values = append(values, sessionJSON{
+				Profile:          session.ProfileName,
+				RoleARN:          session.Profile.RoleARN,
+				RoleSessionName:  session.Profile.RoleSessionName,
+				MFASerial:        session.Profile.MFASerial,
+				SourceProfile:    session.Profile.SourceProfile,
+				Expiration:       session.Profile.Expires.UTC().Format(time.RFC3339),
+				RemainingSeconds: remaining,
+				Status:           sessionStatus(session),
+			})
+		}
+
+		b, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(out, "%s\n", b)
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROFILE\tROLE ARN\tSESSION NAME\tMFA SERIAL\tEXPIRES\tREMAINING\tSTATUS")
+
+	for _, session := range sessions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			session.ProfileName,
+			orDash(session.Profile.RoleARN),
+			orDash(session.Profile.RoleSessionName),
+			orDash(session.Profile.MFASerial),
+			session.Profile.Expires.Local().Format("2006-01-02 15:04:05"),
+			formatRemaining(session.Remaining),
+			sessionStatus(session),
+		)
+	}
+
+	return w.Flush()
+}
+
+// runList prints the sessions that assume-role has cached credentials for.
+func runList(ctx *commandContext) int {
+	if len(ctx.opts.args) > 0 {
+		return ctx.fail(fmt.Errorf("Unknown argument: %s", ctx.opts.args[0]))
+	}
+
+	app, err := loadConfigAndApp(ctx)
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	sessions, err := app.CachedSessions()
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	output := ctx.opts.output
+	if output == "" {
+		output = outputTable
+	}
+
+	if err := printSessions(sessions, output, ctx.stdout); err != nil {
+		return ctx.fail(err)
+	}
+
+	return 0
+}
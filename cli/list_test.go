@@ -0,0 +1,107 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	assumerole "github.com/uber/assume-role-cli"
+)
+
+var testSessions = []assumerole.CachedSession{
+	{
+		ProfileName: "000000000000-admin",
+		Profile: &assumerole.ProfileConfiguration{
+			Expires:         time.Date(2018, 4, 23, 13, 0, 0, 0, time.UTC),
+			MFASerial:       "arn:aws:iam::000000000000:mfa/bob",
+			RoleARN:         "arn:aws:iam::000000000000:role/admin",
+			RoleSessionName: "bob",
+		},
+		Remaining: 65 * time.Minute,
+	},
+	{
+		ProfileName: "000000000000-ops",
+		Profile: &assumerole.ProfileConfiguration{
+			Expires:         time.Date(2018, 4, 23, 11, 0, 0, 0, time.UTC),
+			RoleARN:         "arn:aws:iam::000000000000:role/ops",
+			RoleSessionName: "bob",
+			SourceProfile:   "work",
+		},
+		Remaining:    -time.Hour,
+		NeedsRefresh: true,
+	},
+}
+
+func TestPrintSessionsTable(t *testing.T) {
+	out := &bytes.Buffer{}
+	require.NoError(t, printSessions(testSessions, outputTable, out))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"PROFILE", "ROLE", "ARN", "SESSION", "NAME", "MFA", "SERIAL", "EXPIRES", "REMAINING", "STATUS"}, strings.Fields(lines[0]))
+	assert.Regexp(t, `^000000000000-admin +arn:aws:iam::000000000000:role/admin +bob +arn:aws:iam::000000000000:mfa/bob +2018-04-2\d \d\d:00:00 +1h05m +valid$`, lines[1])
+	assert.Regexp(t, `^000000000000-ops +arn:aws:iam::000000000000:role/ops +bob +- +2018-04-2\d \d\d:00:00 +- +expired$`, lines[2])
+}
+
+func TestPrintSessionsJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	require.NoError(t, printSessions(testSessions, outputJSON, out))
+
+	assert.JSONEq(t, `[
+		{
+			"profile": "000000000000-admin",
+			"role_arn": "arn:aws:iam::000000000000:role/admin",
+			"role_session_name": "bob",
+			"mfa_serial": "arn:aws:iam::000000000000:mfa/bob",
+			"expiration": "2018-04-23T13:00:00Z",
+			"remaining_seconds": 3900,
+			"status": "valid"
+		},
+		{
+			"profile": "000000000000-ops",
+			"role_arn": "arn:aws:iam::000000000000:role/ops",
+			"role_session_name": "bob",
+			"source_profile": "work",
+			"expiration": "2018-04-23T11:00:00Z",
+			"remaining_seconds": 0,
+			"status": "expired"
+		}
+	]`, out.String())
+}
+
+func TestSessionStatus(t *testing.T) {
+	assert.Equal(t, statusValid, sessionStatus(assumerole.CachedSession{Remaining: time.Hour}))
+	assert.Equal(t, statusExpiring, sessionStatus(assumerole.CachedSession{Remaining: 10 * time.Minute, NeedsRefresh: true}))
+	assert.Equal(t, statusExpired, sessionStatus(assumerole.CachedSession{Remaining: -time.Second, NeedsRefresh: true}))
+}
+
+func TestFormatRemaining(t *testing.T) {
+	assert.Equal(t, "-", formatRemaining(-time.Minute))
+	assert.Equal(t, "<1m", formatRemaining(30*time.Second))
+	assert.Equal(t, "42m", formatRemaining(42*time.Minute+10*time.Second))
+	assert.Equal(t, "11h59m", formatRemaining(12*time.Hour-time.Second))
+}
+
+func TestParseOutput(t *testing.T) {
+	_, err := parseOutput("yaml")
+	assert.Error(t, err)
+}
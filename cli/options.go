@@ -18,79 +18,255 @@ package cli
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 )
 
 // cliOpts are the available options for the assume-role CLI.
 type cliOpts struct {
 	// args is for collecting the remaidner arguments (that are not part of
-	// assume-role's options). We stop parsing on first unknown option and then
-	// collect the remaining args because they will be executed.
+	// assume-role's options). We stop parsing on the first argument that isn't
+	// a flag and then collect the remaining args because they will be executed.
 	args []string
 
 	// role is the role name or ARN that the user wants to assume
 	role string
 
+	// account is the name or ID of the account of the role
+	account string
+
+	// refreshAccounts refreshes the cached accounts from AWS Organizations
+	refreshAccounts bool
+
+	// via is the list of roles that are assumed, in order, before role; these
+	// come from repeating the --role option
+	via []string
+
 	// roleSessionName overrides the default session name
 	roleSessionName string
 
 	// forceRefresh causes credentials to be refreshed irrespective of the expiry
 	forceRefresh bool
-}
 
-// argumentList is a special slice of strings that includes helpers for
-// processing.
-type argumentList []string
+	// duration overrides the configured lifetime of the credentials
+	duration time.Duration
+
+	// policyFile is the path to a JSON policy document used as an inline
+	// session policy
+	policyFile string
+
+	// policyARNs are the ARNs of managed policies used as session policies
+	policyARNs []string
+
+	// tags are the session tags to set on the session
+	tags map[string]string
+
+	// transitiveTagKeys are the keys of the tags that are passed on to
+	// sessions of roles assumed from this one
+	transitiveTagKeys []string
+
+	// externalID is the external ID required by the role's trust policy
+	externalID string
+
+	// sourceProfile is the AWS profile with the credentials to assume the
+	// role with
+	sourceProfile string
+
+	// region overrides the configured AWS region
+	region string
+
+	// stsRegionalEndpoint uses the regional STS endpoint
+	stsRegionalEndpoint bool
+
+	// fips uses FIPS endpoints
+	fips bool
+
+	// stsEndpointURL and iamEndpointURL override the STS and IAM endpoints
+	stsEndpointURL string
+	iamEndpointURL string
+
+	// credentialProcess prints the credentials in the credential_process
+	// format of the AWS SDKs instead of running a command
+	credentialProcess bool
+
+	// samlAssertionFile is the path to a file holding a base64 encoded SAML
+	// assertion to assume the role with, or "-" for stdin
+	samlAssertionFile string
+
+	// format is the output format of the environment variables when no
+	// command is given; empty detects it from $SHELL
+	format string
+
+	// unset prints the commands that clear the environment variables instead
+	// of assuming a role
+	unset bool
+
+	// profile is the AWS profile written by configure-profile
+	profile string
+
+	// output is the output format of list: table or json
+	output string
+
+	// all clears the cached credentials of every role
+	all bool
+
+	// mfa clears the cached MFA session
+	mfa bool
+
+	// destination is the console URL that console signs in to
+	destination string
+
+	// consoleDuration is the lifetime of the console session
+	consoleDuration time.Duration
+
+	// open opens the console sign-in URL in the browser
+	open bool
+
+	// federationEndpointURL overrides the federation endpoint
+	federationEndpointURL string
+
+	// listen is the address that serve listens on
+	listen string
+
+	// allowRemote lets serve listen on addresses other than loopback ones
+	allowRemote bool
+
+	// imds serves the credentials like the EC2 instance metadata service
+	imds bool
+
+	// supervise runs the command as a child process instead of replacing
+	// assume-role with it
+	supervise bool
+
+	// refresh supervises the command and serves it credentials that are
+	// refreshed ahead of expiry
+	refresh bool
+
+	// socket is the path of the agent's Unix socket
+	socket string
+
+	// lifetime and idleTimeout limit how long the agent runs
+	lifetime    time.Duration
+	idleTimeout time.Duration
+}
 
 // used both here and in tests
 var errNoRole = errors.New("Missing required argument: --role")
 
-// Next returns the arg from the beginning of the argument list and
-// removes it from the list.
-func (a *argumentList) Next() string {
-	s := *a
-
-	if len(s) == 0 {
-		return ""
+// parseTag parses a session tag in the form "key=value".
+func parseTag(tag string) (key string, value string, err error) {
+	parts := strings.SplitN(tag, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("expected key=value, got %q", tag)
 	}
 
-	// shift / mutate slice
-	next, newList := s[0], s[1:]
-	*a = newList
+	return parts[0], parts[1], nil
+}
+
+// addAssumeRoleFlags adds the flags that choose the role to assume and the
+// parameters of its session.
+func addAssumeRoleFlags(f *flagSet, opts *cliOpts) {
+	f.String(&opts.account, "account", "string", "Name or ID of the account of the role; --role is\nthen the name of the role in that account")
+	f.Bool(&opts.refreshAccounts, "refresh-accounts", "Refresh the cached accounts from AWS Organizations")
+	f.Func("role", "string", "Name of the role to assume; repeat to assume\neach role in turn using the one before it", func(value string) error {
+		if opts.role != "" {
+			opts.via = append(opts.via, opts.role)
+		}
+		opts.role = value
+		return nil
+	})
+	f.String(&opts.roleSessionName, "role-session-name", "string", "Name of the session for the assumed role")
+	f.Bool(&opts.forceRefresh, "force-refresh", "Forces credentials refresh irrespective of their expiry").short = "f"
+	f.Duration(&opts.duration, "duration", "Lifetime of the credentials (e.g. 1h, 90m)")
+	f.String(&opts.externalID, "external-id", "string", "External ID required by the role's trust policy")
+	f.String(&opts.samlAssertionFile, "saml-assertion-file", "path", "Assume the role with the base64 encoded SAML\nassertion in this file, or \"-\" for stdin")
+	f.String(&opts.policyFile, "policy-file", "string", "Path to a JSON policy document to scope down the\ncredentials with")
+	f.StringSlice(&opts.policyARNs, "policy-arn", "string", "ARN of a managed policy to scope down the credentials\nwith; can be repeated")
+	f.Func("tag", "key=value", "Session tag to set; can be repeated", func(value string) error {
+		return opts.addTag(value, false)
+	})
+	f.Func("transitive-tag", "key=value", "Session tag to set and pass on to roles assumed\nfrom this one; can be repeated", func(value string) error {
+		return opts.addTag(value, true)
+	})
 
-	return next
+	addAWSFlags(f, opts)
 }
 
-func parseOptions(args argumentList) (*cliOpts, error) {
-	opts := &cliOpts{}
+// addAWSFlags adds the flags that choose the source credentials, region and
+// endpoints to use.
+func addAWSFlags(f *flagSet, opts *cliOpts) {
+	f.String(&opts.sourceProfile, "source-profile", "string", "AWS profile with the credentials to assume the\nrole with, instead of the default credentials")
+	f.String(&opts.region, "region", "string", "AWS region to use, and to run the command in")
+	f.Bool(&opts.stsRegionalEndpoint, "sts-regional-endpoint", "Use the STS endpoint of the region rather than the\nglobal endpoint")
+	f.Bool(&opts.fips, "fips", "Use FIPS endpoints")
+	f.String(&opts.stsEndpointURL, "sts-endpoint-url", "url", "Custom STS endpoint, e.g. for a VPC endpoint or\nLocalStack")
+	f.String(&opts.iamEndpointURL, "iam-endpoint-url", "url", "Custom IAM endpoint, e.g. for LocalStack")
+}
 
-ArgsLoop:
-	for len(args) > 0 {
-		switch arg := args.Next(); arg {
+// addOutputFlags adds the flags that choose how credentials are printed.
+func addOutputFlags(f *flagSet, opts *cliOpts) {
+	addFormatFlag(f, opts)
+	f.Bool(&opts.unset, "unset", "Print the commands that clear the environment\nvariables set by assume-role")
+	f.Bool(&opts.credentialProcess, "credential-process", "Print the credentials for the credential_process\nsetting of an AWS profile")
+}
 
-		case "-f":
-			opts.forceRefresh = true
+// addFormatFlag adds the flag that chooses the format environment variables
+// are printed in.
+func addFormatFlag(f *flagSet, opts *cliOpts) {
+	f.Func("format", "string", "Output format of the environment variables: bash,\nzsh, fish, powershell, dotenv, json or docker", func(value string) error {
+		format, err := parseFormat(value)
+		if err != nil {
+			return err
+		}
+		opts.format = format
+		return nil
+	})
+}
 
-		case "--force-refresh":
-			opts.forceRefresh = true
+// addSuperviseFlags adds the flags that run the command as a child process.
+func addSuperviseFlags(f *flagSet, opts *cliOpts) {
+	f.Bool(&opts.supervise, "supervise", "Run the command as a child process, passing on\nsignals and exiting with its exit code")
+	f.Bool(&opts.refresh, "refresh", "Supervise the command, and serve it credentials that\nare refreshed ahead of expiry")
+}
 
-		case "--role":
-			opts.role = args.Next()
+// addTag adds a session tag in the form "key=value", which is passed on to
+// roles assumed from this one if transitive is set.
+func (opts *cliOpts) addTag(tag string, transitive bool) error {
+	key, value, err := parseTag(tag)
+	if err != nil {
+		return err
+	}
 
-		case "--role-session-name":
-			opts.roleSessionName = args.Next()
+	if opts.tags == nil {
+		opts.tags = make(map[string]string)
+	}
+	opts.tags[key] = value
 
-		case "--":
-			// Stop parsing and add remaining args to opts.args
-			opts.args = append(opts.args, args...)
-			break ArgsLoop
+	if transitive {
+		opts.transitiveTagKeys = append(opts.transitiveTagKeys, key)
+	}
 
-		default:
-			// Stop parsing and add this arg + remaining args to opts.args
-			opts.args = append(opts.args, arg)
-			opts.args = append(opts.args, args...)
-			break ArgsLoop
-		}
+	return nil
+}
+
+// addEnvFlags adds the flags of the env command, which are also the flags of
+// assume-role without a command.
+func addEnvFlags(f *flagSet, opts *cliOpts) {
+	addAssumeRoleFlags(f, opts)
+	addOutputFlags(f, opts)
+}
+
+// parseOptions parses the flags of assume-role without a command, returning
+// errNoRole along with the options if no role is given.
+func parseOptions(args []string) (*cliOpts, error) {
+	opts := &cliOpts{}
+
+	args, err := commandFlags(rootCommand, opts).Parse(args)
+	if err != nil {
+		return opts, err
 	}
+	opts.args = args
 
 	if opts.role == "" {
 		return opts, errNoRole
@@ -17,6 +17,7 @@ package cli
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -70,3 +71,96 @@ func TestParseOptionsForceRefreshShort(t *testing.T) {
 	assert.Equal(t, true, cliOpts.forceRefresh)
 	assert.Equal(t, []string{"ls", "-l"}, cliOpts.args)
 }
+
+func TestParseOptionsDuration(t *testing.T) {
+	cliOpts, err := parseOptions([]string{"--role", testRole, "--duration", "4h", "ls", "-l"})
+	assert.NoError(t, err)
+	assert.Equal(t, testRole, cliOpts.role)
+	assert.Equal(t, 4*time.Hour, cliOpts.duration)
+	assert.Equal(t, []string{"ls", "-l"}, cliOpts.args)
+}
+
+func TestParseOptionsInvalidDuration(t *testing.T) {
+	_, err := parseOptions([]string{"--role", testRole, "--duration", "forever", "ls", "-l"})
+	assert.Error(t, err)
+}
+
+func TestParseOptionsRoleChain(t *testing.T) {
+	cliOpts, err := parseOptions([]string{"--role", "jump", "--role", "middle", "--role", testRole, "ls", "-l"})
+	assert.NoError(t, err)
+	assert.Equal(t, testRole, cliOpts.role)
+	assert.Equal(t, []string{"jump", "middle"}, cliOpts.via)
+	assert.Equal(t, []string{"ls", "-l"}, cliOpts.args)
+}
+
+func TestParseOptionsSessionPolicy(t *testing.T) {
+	cliOpts, err := parseOptions([]string{
+		"--role", testRole,
+		"--policy-file", "policy.json",
+		"--policy-arn", "arn:aws:iam::aws:policy/ReadOnlyAccess",
+		"--policy-arn", "arn:aws:iam::675470192105:policy/bucket",
+		"ls", "-l",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "policy.json", cliOpts.policyFile)
+	assert.Equal(t, []string{"arn:aws:iam::aws:policy/ReadOnlyAccess", "arn:aws:iam::675470192105:policy/bucket"}, cliOpts.policyARNs)
+	assert.Equal(t, []string{"ls", "-l"}, cliOpts.args)
+}
+
+func TestParseOptionsTags(t *testing.T) {
+	cliOpts, err := parseOptions([]string{"--role", testRole, "--tag", "team=infra", "--transitive-tag", "ticket=OPS-1=2", "ls", "-l"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "infra", "ticket": "OPS-1=2"}, cliOpts.tags)
+	assert.Equal(t, []string{"ticket"}, cliOpts.transitiveTagKeys)
+	assert.Equal(t, []string{"ls", "-l"}, cliOpts.args)
+}
+
+func TestParseOptionsInvalidTag(t *testing.T) {
+	_, err := parseOptions([]string{"--role", testRole, "--tag", "team", "ls", "-l"})
+	assert.Error(t, err)
+}
+
+func TestParseOptionsExternalID(t *testing.T) {
+	cliOpts, err := parseOptions([]string{"--role", testRole, "--external-id", "vendor-123", "ls", "-l"})
+	assert.NoError(t, err)
+	assert.Equal(t, "vendor-123", cliOpts.externalID)
+	assert.Equal(t, []string{"ls", "-l"}, cliOpts.args)
+}
+
+func TestParseOptionsSAMLAssertionFile(t *testing.T) {
+	opts, err := parseOptions([]string{"--saml-assertion-file", "-", "--role", "admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, "-", opts.samlAssertionFile)
+	assert.Equal(t, "admin", opts.role)
+}
+
+func TestParseOptionsAccount(t *testing.T) {
+	opts, err := parseOptions([]string{"--account", "prod", "--role", "admin", "--refresh-accounts"})
+	assert.NoError(t, err)
+	assert.Equal(t, "prod", opts.account)
+	assert.Equal(t, "admin", opts.role)
+	assert.True(t, opts.refreshAccounts)
+}
+
+func TestParseOptionsEndpoints(t *testing.T) {
+	opts, err := parseOptions([]string{
+		"--role", "admin",
+		"--region", "us-gov-west-1",
+		"--sts-regional-endpoint",
+		"--fips",
+		"--sts-endpoint-url", "http://localhost:4566",
+		"--iam-endpoint-url", "http://localhost:4566",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "us-gov-west-1", opts.region)
+	assert.True(t, opts.stsRegionalEndpoint)
+	assert.True(t, opts.fips)
+	assert.Equal(t, "http://localhost:4566", opts.stsEndpointURL)
+	assert.Equal(t, "http://localhost:4566", opts.iamEndpointURL)
+}
+
+func TestParseOptionsSourceProfile(t *testing.T) {
+	opts, err := parseOptions([]string{"--source-profile", "work", "--role", "admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, "work", opts.sourceProfile)
+}
@@ -0,0 +1,82 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	assumerole "github.com/uber/assume-role-cli"
+)
+
+// sharedFileEnvVarNames are the environment variables with the paths of the
+// shared AWS config and credentials files.
+var sharedFileEnvVarNames = []string{"AWS_CONFIG_FILE", "AWS_SHARED_CREDENTIALS_FILE"}
+
+// refreshErrorHandler returns the handler for errors refreshing credentials in
+// the background, which says when the current credentials expire. Refreshing
+// that would need a prompt is only reported once, as it isn't tried again
+// until the credentials are refreshed in another terminal.
+func refreshErrorHandler(ctx *commandContext, refresher *assumerole.Refresher) func(error) {
+	return func(err error) {
+		expires := refresher.Expires().Local().Format("15:04:05")
+
+		if assumerole.IsPromptRequiredError(err) {
+			fmt.Fprintf(ctx.stderr, "WARNING: Could not refresh credentials, which expire at %s: %v; they're picked up from the cache once a minute until then\n", expires, err)
+			return
+		}
+
+		fmt.Fprintf(ctx.stderr, "ERROR: Could not refresh credentials, which expire at %s; trying again in a minute: %v\n", expires, err)
+	}
+}
+
+// runRefreshing runs a command as a child process that gets the credentials of
+// the role from a container credentials endpoint, which serves fresh
+// credentials ahead of expiry. The AWS SDKs fetch credentials from it again
+// once the ones they have expire, which keeps commands that run for longer
+// than the credentials last working. The shared AWS config and credentials
+// files are hidden from the command, as their default profile would take
+// precedence over the endpoint.
+func runRefreshing(ctx *commandContext, role *assumedRole) int {
+	listener, err := net.Listen("tcp", defaultListenAddress)
+	if err != nil {
+		return ctx.fail(err)
+	}
+	defer listener.Close()
+
+	// The command has the terminal, so it can't be shared with MFA prompts
+	refresher := role.app.NewRefresher(role.params, role.credentials)
+	refresher.DisablePrompts()
+
+	handler, vars, err := containerEndpoint(refresher, listener.Addr().String())
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	stopServing := startServing(ctx, refresher, listener, handler)
+	defer stopServing()
+
+	env := withoutEnv(os.Environ(), append(credentialEnvVarNames, sharedFileEnvVarNames...))
+	for _, name := range sharedFileEnvVarNames {
+		env = append(env, fmt.Sprintf("%s=%s", name, os.DevNull))
+	}
+	env = append(env, vars...)
+	env = append(env, role.regionEnv()...)
+
+	return supervise(ctx, ctx.opts.args, env)
+}
@@ -0,0 +1,178 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	assumerole "github.com/uber/assume-role-cli"
+	"github.com/uber/assume-role-cli/mocks"
+)
+
+// testClock is a clock that only moves when it is set.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// newTestRefresherApp returns an app for refreshing credentials whose cache
+// is mockAWSConfig, if it is given.
+func newTestRefresherApp(t *testing.T, mockAWSConfig *mocks.MockAWSConfigProvider, clock assumerole.Clock) *assumerole.App {
+	mockCtrl := gomock.NewController(t)
+
+	mockAWS := mocks.NewMockAWSProvider(mockCtrl)
+	mockAWS.EXPECT().CurrentPrincipalARN().Return("arn:aws:iam::000000000000:user/bob", nil).AnyTimes()
+
+	if mockAWSConfig == nil {
+		mockAWSConfig = mocks.NewMockAWSConfigProvider(mockCtrl)
+	}
+
+	app, err := assumerole.NewApp(
+		assumerole.WithAWS(mockAWS),
+		assumerole.WithAWSConfig(mockAWSConfig),
+		assumerole.WithClock(clock),
+		assumerole.WithConfig(&assumerole.Config{RolePrefix: "arn:aws:iam::000000000000:role/"}),
+	)
+	require.NoError(t, err)
+
+	return app
+}
+
+func TestRunRefreshingEnv(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	ctx := &commandContext{
+		stdout: stdout,
+		stderr: &bytes.Buffer{},
+		opts:   &cliOpts{args: []string{"sh", "-c", "env"}},
+	}
+
+	defer setenv("AWS_ACCESS_KEY_ID", "LEAKED")()
+	defer setenv("AWS_PROFILE", "work")()
+
+	role := &assumedRole{
+		app:         newTestRefresherApp(t, nil, &testClock{now: time.Now()}),
+		params:      assumerole.AssumeRoleParameters{UserRole: "admin"},
+		credentials: &assumerole.TemporaryCredentials{Expires: time.Now().Add(time.Hour)},
+		region:      "eu-west-1",
+	}
+
+	require.Equal(t, 0, runRefreshing(ctx, role))
+
+	env := strings.Split(stdout.String(), "\n")
+	assert.Contains(t, env, "AWS_CONFIG_FILE="+os.DevNull)
+	assert.Contains(t, env, "AWS_SHARED_CREDENTIALS_FILE="+os.DevNull)
+	assert.Contains(t, env, "AWS_REGION=eu-west-1")
+	assert.Regexp(t, `(?m)^AWS_CONTAINER_CREDENTIALS_FULL_URI=http://127\.0\.0\.1:\d+/$`, stdout.String())
+	assert.Regexp(t, `(?m)^AWS_CONTAINER_AUTHORIZATION_TOKEN=[0-9a-f]{64}$`, stdout.String())
+	assert.NotContains(t, stdout.String(), "LEAKED")
+	assert.NotContains(t, stdout.String(), "AWS_PROFILE=")
+}
+
+func TestRefreshingEndpointWithSDK(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	now := time.Now()
+	clock := &testClock{now: now.Add(-time.Hour)}
+
+	first := &assumerole.TemporaryCredentials{
+		AccessKeyID:     "FIRST",
+		SecretAccessKey: "secret1",
+		SessionToken:    "token1",
+		Expires:         now.Add(2 * time.Minute),
+	}
+	second := &assumerole.TemporaryCredentials{
+		AccessKeyID:     "SECOND",
+		SecretAccessKey: "secret2",
+		SessionToken:    "token2",
+		Expires:         now.Add(time.Hour),
+	}
+
+	// The second credentials were refreshed in the cache, e.g. by assume-role
+	// in another terminal
+	mockAWSConfig := mocks.NewMockAWSConfigProvider(mockCtrl)
+	mockAWSConfig.EXPECT().GetProfile(gomock.Any()).Return(&assumerole.ProfileConfiguration{Expires: second.Expires}, nil)
+	mockAWSConfig.EXPECT().GetCredentials(gomock.Any()).Return(second, nil)
+
+	app := newTestRefresherApp(t, mockAWSConfig, clock)
+	refresher := app.NewRefresher(assumerole.AssumeRoleParameters{UserRole: "admin"}, first)
+	refresher.DisablePrompts()
+
+	listener, err := net.Listen("tcp", defaultListenAddress)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	handler, vars, err := containerEndpoint(refresher, listener.Addr().String())
+	require.NoError(t, err)
+
+	server := &http.Server{Handler: handler}
+	defer server.Close()
+	go server.Serve(listener)
+
+	// Point the AWS SDK at the endpoint like the environment of the command
+	for _, name := range credentialEnvVarNames {
+		defer setenv(name, "")()
+	}
+	for _, name := range sharedFileEnvVarNames {
+		defer setenv(name, os.DevNull)()
+	}
+	for _, v := range vars {
+		key, value := splitVar(v)
+		defer setenv(key, value)()
+	}
+
+	sess, err := session.NewSession()
+	require.NoError(t, err)
+
+	creds, err := sess.Config.Credentials.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "FIRST", creds.AccessKeyID)
+
+	// Once the first credentials are within refresh_before_expiry of
+	// expiring, the endpoint serves fresh ones. The SDK fetches them again
+	// as it considers the first ones expired five minutes ahead of time.
+	clock.Set(now)
+
+	creds, err = sess.Config.Credentials.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "SECOND", creds.AccessKeyID)
+	assert.Equal(t, "secret2", creds.SecretAccessKey)
+	assert.Equal(t, "token2", creds.SessionToken)
+}
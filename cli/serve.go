@@ -0,0 +1,284 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	assumerole "github.com/uber/assume-role-cli"
+)
+
+// Environment variables of the ECS container credentials provider, which the
+// AWS SDKs get credentials from an HTTP endpoint with.
+const (
+	containerCredentialsURIEnv = "AWS_CONTAINER_CREDENTIALS_FULL_URI"
+	containerAuthorizationEnv  = "AWS_CONTAINER_AUTHORIZATION_TOKEN"
+)
+
+// defaultListenAddress is the address serve listens on by default: a random
+// port on the loopback interface, which is the only plain HTTP address the
+// AWS SDKs accept for the endpoint.
+const defaultListenAddress = "127.0.0.1:0"
+
+// authorizationTokenLen is the number of random bytes in the authorization
+// token of the endpoint.
+const authorizationTokenLen = 32
+
+// credentialEnvVarNames are the environment variables that the AWS SDKs look
+// for credentials in before the container credentials endpoint and the
+// instance metadata service, or that point them elsewhere, so they're removed
+// from the environment of commands run by serve.
+var credentialEnvVarNames = []string{
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_PROFILE",
+	"AWS_DEFAULT_PROFILE",
+	"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI",
+	containerCredentialsURIEnv,
+	containerAuthorizationEnv,
+	"AWS_EC2_METADATA_DISABLED",
+	imdsEndpointEnv,
+	"AWS_EC2_METADATA_SERVICE_ENDPOINT_MODE",
+}
+
+// containerCredentials is the response of the ECS container credentials
+// endpoint.
+type containerCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+}
+
+// credentialsHandler serves the credentials of a refresher in the format of
+// the ECS container credentials endpoint, to requests with the authorization
+// token.
+func credentialsHandler(refresher *assumerole.Refresher, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		credentials, err := refresher.Credentials()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Could not get credentials: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, containerCredentials{
+			AccessKeyID:     credentials.AccessKeyID,
+			SecretAccessKey: credentials.SecretAccessKey,
+			Token:           credentials.SessionToken,
+			Expiration:      credentials.Expires.UTC().Format(time.RFC3339),
+		})
+	})
+}
+
+// newAuthorizationToken returns a random token that clients of the endpoint
+// must send in the Authorization header.
+func newAuthorizationToken() (string, error) {
+	b := make([]byte, authorizationTokenLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// withoutEnv returns the environment variables in env, except for the ones
+// with the given names.
+func withoutEnv(env []string, names []string) []string {
+	var result []string
+
+	for _, v := range env {
+		key, _ := splitVar(v)
+
+		remove := false
+		for _, name := range names {
+			if key == name {
+				remove = true
+				break
+			}
+		}
+
+		if !remove {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+// checkListenAddress returns an error if the address isn't on a loopback
+// interface, as anyone who can reach the endpoint can get the credentials
+// with --imds, and the authorization token is sent in plain HTTP otherwise.
+func checkListenAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+
+	return fmt.Errorf("refusing to serve credentials on %s, which other hosts can reach; use a loopback address, or --allow-remote", address)
+}
+
+// waitForSignal blocks until assume-role is interrupted or terminated.
+func waitForSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	<-signals
+}
+
+// containerEndpoint returns the handler of a container credentials endpoint
+// for the credentials of refresher, served at address, and the environment
+// variables that point the AWS SDKs at it.
+func containerEndpoint(refresher *assumerole.Refresher, address string) (http.Handler, []string, error) {
+	token, err := newAuthorizationToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vars := []string{
+		fmt.Sprintf("%s=http://%s/", containerCredentialsURIEnv, address),
+		fmt.Sprintf("%s=%s", containerAuthorizationEnv, token),
+	}
+
+	return credentialsHandler(refresher, token), vars, nil
+}
+
+// startServing serves handler on listener, and refreshes the credentials of
+// refresher ahead of expiry in the background. It returns the function that
+// stops both.
+func startServing(ctx *commandContext, refresher *assumerole.Refresher, listener net.Listener, handler http.Handler) func() {
+	stop := make(chan struct{})
+	go refresher.Run(stop, nil, refreshErrorHandler(ctx, refresher))
+
+	server := &http.Server{Handler: handler}
+	go server.Serve(listener)
+
+	return func() {
+		server.Close()
+		close(stop)
+	}
+}
+
+// runServe assumes a role and serves its credentials on a local endpoint that
+// is compatible with the ECS container credentials endpoint, or with the EC2
+// instance metadata service with --imds, refreshing them ahead of expiry. The
+// given command is run with the environment variables that point the AWS
+// SDKs at the endpoint; without one, the variables are printed and the
+// endpoint is served until assume-role is interrupted.
+func runServe(ctx *commandContext) int {
+	opts := ctx.opts
+
+	format := opts.format
+	if format == "" {
+		format = detectFormat(os.Getenv("SHELL"))
+	}
+
+	listenAddress := opts.listen
+	if listenAddress == "" {
+		listenAddress = defaultListenAddress
+	}
+
+	if !opts.allowRemote {
+		if err := checkListenAddress(listenAddress); err != nil {
+			return ctx.fail(err)
+		}
+	}
+
+	role, err := assumeRole(ctx, ctx.stdin, ctx.stderr)
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return ctx.fail(err)
+	}
+	defer listener.Close()
+
+	// A command that is run has the terminal, so it can't be shared with MFA
+	// prompts; without one, assume-role can still prompt while it serves
+	refresher := role.app.NewRefresher(role.params, role.credentials)
+	if len(opts.args) > 0 {
+		refresher.DisablePrompts()
+	}
+	address := listener.Addr().String()
+
+	var handler http.Handler
+	var vars []string
+
+	if opts.imds {
+		roleARN, err := refresher.RoleARN()
+		if err != nil {
+			return ctx.fail(err)
+		}
+
+		handler = newIMDSServer(refresher, roleARN)
+		vars = []string{fmt.Sprintf("%s=http://%s", imdsEndpointEnv, address)}
+
+		fmt.Fprintf(ctx.stderr, "WARNING: the instance metadata endpoint needs no secret, so any process on this machine can get the credentials of %s from %s\n", roleARN, address)
+	} else {
+		handler, vars, err = containerEndpoint(refresher, address)
+		if err != nil {
+			return ctx.fail(err)
+		}
+	}
+	vars = append(vars, role.regionEnv()...)
+
+	stopServing := startServing(ctx, refresher, listener, handler)
+	defer stopServing()
+
+	if len(opts.args) > 0 {
+		env := append(withoutEnv(os.Environ(), credentialEnvVarNames), vars...)
+		return supervise(ctx, opts.args, env)
+	}
+
+	if err := printVars(vars, format, ctx.stdout); err != nil {
+		return ctx.fail(err)
+	}
+
+	fmt.Fprintf(ctx.stderr, "Serving credentials on %s until interrupted\n", address)
+	waitForSignal()
+
+	return 0
+}
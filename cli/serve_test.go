@@ -0,0 +1,113 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	assumerole "github.com/uber/assume-role-cli"
+	"github.com/uber/assume-role-cli/mocks"
+)
+
+// newTestRefresher returns a refresher for role with credentials that don't
+// need refreshing, so that AWS is never called.
+func newTestRefresher(t *testing.T, role string, expires time.Time) *assumerole.Refresher {
+	mockCtrl := gomock.NewController(t)
+
+	app, err := assumerole.NewApp(
+		assumerole.WithAWS(mocks.NewMockAWSProvider(mockCtrl)),
+		assumerole.WithAWSConfig(mocks.NewMockAWSConfigProvider(mockCtrl)),
+		assumerole.WithConfig(&assumerole.Config{}),
+	)
+	require.NoError(t, err)
+
+	return app.NewRefresher(assumerole.AssumeRoleParameters{UserRole: role}, &assumerole.TemporaryCredentials{
+		AccessKeyID:     "ABC123",
+		SecretAccessKey: "supersecret",
+		SessionToken:    "123tok",
+		Expires:         expires,
+	})
+}
+
+func TestCredentialsHandler(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	refresher := newTestRefresher(t, "admin", expires)
+
+	server := httptest.NewServer(credentialsHandler(refresher, "token"))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "token")
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var credentials containerCredentials
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&credentials))
+	assert.Equal(t, containerCredentials{
+		AccessKeyID:     "ABC123",
+		SecretAccessKey: "supersecret",
+		Token:           "123tok",
+		Expiration:      expires.UTC().Format(time.RFC3339),
+	}, credentials)
+}
+
+func TestWithoutEnv(t *testing.T) {
+	env := []string{"HOME=/home/bob", "AWS_PROFILE=dev", "AWS_ACCESS_KEY_ID=ABC123", "AWS_REGION=us-west-2"}
+
+	assert.Equal(t, []string{"HOME=/home/bob", "AWS_REGION=us-west-2"}, withoutEnv(env, credentialEnvVarNames))
+}
+
+func TestNewAuthorizationToken(t *testing.T) {
+	token, err := newAuthorizationToken()
+	require.NoError(t, err)
+	assert.Len(t, token, 2*authorizationTokenLen)
+
+	other, err := newAuthorizationToken()
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func TestCheckListenAddress(t *testing.T) {
+	for _, address := range []string{defaultListenAddress, "127.0.0.1:8080", "127.1.2.3:80", "[::1]:8080", "localhost:8080"} {
+		assert.NoError(t, checkListenAddress(address), address)
+	}
+
+	for _, address := range []string{":8080", "0.0.0.0:8080", "[::]:8080", "10.0.0.1:8080", "169.254.169.254:80", "example.com:8080"} {
+		err := checkListenAddress(address)
+		if assert.Error(t, err, address) {
+			assert.Contains(t, err.Error(), "--allow-remote")
+		}
+	}
+
+	assert.Error(t, checkListenAddress("127.0.0.1"))
+}
@@ -0,0 +1,123 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// supervisedSignals are the signals that are passed on to a supervised
+// command.
+var supervisedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// terminalSignals are the signals that a terminal sends to every process in
+// its foreground process group, the supervised command included, so they
+// aren't passed on a second time while assume-role is in that group.
+var terminalSignals = []os.Signal{os.Interrupt, syscall.SIGQUIT}
+
+// inForegroundProcessGroup indicates whether assume-role, and so the command
+// it supervises, is in the foreground process group of its controlling
+// terminal. It is a variable so that tests can replace it.
+var inForegroundProcessGroup = func() bool {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+
+	var pgrp int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, tty.Fd(), uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgrp))); errno != 0 {
+		return false
+	}
+
+	return int(pgrp) == syscall.Getpgrp()
+}
+
+// supervise runs a command as a child process rather than replacing
+// assume-role with it, so that assume-role keeps running while the command
+// runs. The signals assume-role is sent are passed on to the command, and its
+// exit code is returned once it exits.
+func supervise(ctx *commandContext, args []string, env []string) int {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = ctx.stdin
+	cmd.Stdout = ctx.stdout
+	cmd.Stderr = ctx.stderr
+
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(ctx.stderr, "ERROR: Could not execute command: %v\n", err)
+		return 127
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, supervisedSignals...)
+	defer func() {
+		signal.Stop(signals)
+		close(signals)
+	}()
+
+	go func() {
+		for sig := range signals {
+			// Ctrl-C at the terminal already reached the command; a
+			// signal that was sent to assume-role alone, e.g. by a process
+			// manager, is passed on
+			if isTerminalSignal(sig) && inForegroundProcessGroup() {
+				continue
+			}
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	return exitCode(cmd.Wait())
+}
+
+// isTerminalSignal indicates whether a signal is one that a terminal sends to
+// its foreground process group.
+func isTerminalSignal(sig os.Signal) bool {
+	for _, terminal := range terminalSignals {
+		if sig == terminal {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exitCode returns the exit code for the result of a command, which is 128
+// plus the signal number when it was killed by a signal, like in a shell.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 1
+	}
+
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return exitErr.ExitCode()
+}
@@ -0,0 +1,107 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupervise(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	ctx := &commandContext{stdout: stdout, stderr: &bytes.Buffer{}, opts: &cliOpts{}}
+
+	assert.Equal(t, 3, supervise(ctx, []string{"sh", "-c", "echo $FOO; exit 3"}, append(os.Environ(), "FOO=bar")))
+	assert.Equal(t, "bar\n", stdout.String())
+}
+
+func TestSuperviseKilled(t *testing.T) {
+	ctx := &commandContext{stdout: &bytes.Buffer{}, stderr: &bytes.Buffer{}, opts: &cliOpts{}}
+
+	// 128 + SIGTERM, like a shell
+	assert.Equal(t, 143, supervise(ctx, []string{"sh", "-c", "kill -TERM $$"}, os.Environ()))
+}
+
+func TestSuperviseNotFound(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	ctx := &commandContext{stdout: &bytes.Buffer{}, stderr: stderr, opts: &cliOpts{}}
+
+	assert.Equal(t, 127, supervise(ctx, []string{"assume-role-test-no-such-command"}, os.Environ()))
+	assert.Contains(t, stderr.String(), "ERROR: Could not execute command:")
+}
+
+func TestSuperviseSignals(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	ctx := &commandContext{stdout: stdout, stderr: &bytes.Buffer{}, opts: &cliOpts{}}
+
+	defer func(f func() bool) { inForegroundProcessGroup = f }(inForegroundProcessGroup)
+	inForegroundProcessGroup = func() bool { return false }
+
+	// Outside of a terminal, e.g. under a process manager, SIGINT is only
+	// sent to assume-role, so it is passed on like SIGTERM
+	script := `trap 'echo INT' INT; trap 'echo TERM; exit 5' TERM
+sleep 0.5; kill -INT $PPID; sleep 0.5; kill -TERM $PPID
+while true; do sleep 0.1; done`
+
+	assert.Equal(t, 5, supervise(ctx, []string{"sh", "-c", script}, os.Environ()))
+	assert.Equal(t, "INT\nTERM\n", stdout.String())
+}
+
+func TestSuperviseSignalsInForeground(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	ctx := &commandContext{stdout: stdout, stderr: &bytes.Buffer{}, opts: &cliOpts{}}
+
+	defer func(f func() bool) { inForegroundProcessGroup = f }(inForegroundProcessGroup)
+	inForegroundProcessGroup = func() bool { return true }
+
+	// The terminal sends SIGINT to the command itself, so it isn't passed on
+	// a second time, while SIGTERM still is
+	script := `trap 'echo INT' INT; trap 'echo TERM; exit 5' TERM
+sleep 0.5; kill -INT $PPID; sleep 0.5; kill -TERM $PPID
+while true; do sleep 0.1; done`
+
+	assert.Equal(t, 5, supervise(ctx, []string{"sh", "-c", script}, os.Environ()))
+	assert.Equal(t, "TERM\n", stdout.String())
+}
+
+func TestInForegroundProcessGroupWithoutTerminal(t *testing.T) {
+	// A command started without a controlling terminal, like under a process
+	// manager, is never in the foreground
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperForegroundProcessGroup$")
+	cmd.Env = append(os.Environ(), "ASSUME_ROLE_TEST_HELPER=1")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+	assert.Contains(t, string(out), "foreground=false")
+}
+
+// TestHelperForegroundProcessGroup is run by
+// TestInForegroundProcessGroupWithoutTerminal in a new session.
+func TestHelperForegroundProcessGroup(t *testing.T) {
+	if os.Getenv("ASSUME_ROLE_TEST_HELPER") != "1" {
+		t.Skip("only run as a helper process")
+	}
+
+	fmt.Printf("foreground=%v\n", inForegroundProcessGroup())
+}
@@ -0,0 +1,116 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	assumerole "github.com/uber/assume-role-cli"
+)
+
+// principalNameLabels are the labels for the name of each type of principal.
+var principalNameLabels = map[string]string{
+	"user":           "User",
+	"assumed-role":   "Role",
+	"federated-user": "Federated user",
+}
+
+// printPrincipal prints the details of a principal that are known.
+func printPrincipal(principal *assumerole.Principal, out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	if principal.AccountName != "" {
+		fmt.Fprintf(w, "Account:\t%s (%s)\n", principal.AccountID, principal.AccountName)
+	} else {
+		fmt.Fprintf(w, "Account:\t%s\n", principal.AccountID)
+	}
+	fmt.Fprintf(w, "Type:\t%s\n", principal.Type)
+
+	if label, ok := principalNameLabels[principal.Type]; ok && principal.Name != "" {
+		fmt.Fprintf(w, "%s:\t%s\n", label, principal.Name)
+	}
+
+	if principal.SessionName != "" && principal.Type == "assumed-role" {
+		fmt.Fprintf(w, "Session name:\t%s\n", principal.SessionName)
+	}
+
+	if session := principal.Session; session != nil {
+		if session.Profile.SourceIdentity != "" {
+			fmt.Fprintf(w, "Source identity:\t%s\n", session.Profile.SourceIdentity)
+		}
+
+		if session.Profile.SourceProfile != "" {
+			fmt.Fprintf(w, "Source profile:\t%s\n", session.Profile.SourceProfile)
+		}
+
+		remaining := "expired"
+		if session.Remaining > 0 {
+			remaining = formatRemaining(session.Remaining) + " left"
+		}
+
+		fmt.Fprintf(w, "Profile:\t%s\n", session.ProfileName)
+		fmt.Fprintf(w, "Expires:\t%s (%s)\n", session.Profile.Expires.Local().Format("2006-01-02 15:04:05"), remaining)
+	}
+
+	fmt.Fprintf(w, "ARN:\t%s\n", principal.ARN)
+
+	return w.Flush()
+}
+
+// runWhoami prints the IAM principal that AWS is called as, along with the
+// session assume-role cached the credentials of, if it did.
+func runWhoami(ctx *commandContext) int {
+	if len(ctx.opts.args) > 0 {
+		return ctx.fail(fmt.Errorf("Unknown argument: %s", ctx.opts.args[0]))
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	// This is the principal of the credentials in the environment, rather
+	// than of the source profile that roles would be assumed with
+	config.SourceProfile = ""
+	applyAWSOptions(config, ctx.opts)
+
+	// The agent calls AWS as its own principal, so only the cached sessions
+	// are looked up through it
+	aws, err := assumerole.NewAWSForConfig(config)
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	app, err := loadApp(ctx.stdin, ctx.stderr, config, assumerole.WithAWS(aws))
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	principal, err := app.WhoAmI(os.Getenv("AWS_ACCESS_KEY_ID"))
+	if err != nil {
+		return ctx.fail(err)
+	}
+
+	if err := printPrincipal(principal, ctx.stdout); err != nil {
+		return ctx.fail(err)
+	}
+
+	return 0
+}
@@ -0,0 +1,81 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	assumerole "github.com/uber/assume-role-cli"
+)
+
+func TestPrintPrincipal(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	err := printPrincipal(&assumerole.Principal{
+		ARN:         "arn:aws:sts::000000000000:assumed-role/admin/bob",
+		AccountID:   "000000000000",
+		Type:        "assumed-role",
+		Name:        "admin",
+		SessionName: "bob",
+		Session: &assumerole.CachedSession{
+			ProfileName: "000000000000-admin",
+			Profile: &assumerole.ProfileConfiguration{
+				Expires:        time.Date(2018, 4, 23, 13, 0, 0, 0, time.UTC),
+				SourceIdentity: "bob",
+				SourceProfile:  "work",
+			},
+			Remaining: 42 * time.Minute,
+		},
+	}, out)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 9)
+	assert.Equal(t, "Account:          000000000000", lines[0])
+	assert.Equal(t, "Type:             assumed-role", lines[1])
+	assert.Equal(t, "Role:             admin", lines[2])
+	assert.Equal(t, "Session name:     bob", lines[3])
+	assert.Equal(t, "Source identity:  bob", lines[4])
+	assert.Equal(t, "Source profile:   work", lines[5])
+	assert.Equal(t, "Profile:          000000000000-admin", lines[6])
+	assert.Regexp(t, `^Expires: +2018-04-2\d \d\d:00:00 \(42m left\)$`, lines[7])
+	assert.Equal(t, "ARN:              arn:aws:sts::000000000000:assumed-role/admin/bob", lines[8])
+}
+
+func TestPrintPrincipalUser(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	err := printPrincipal(&assumerole.Principal{
+		ARN:         "arn:aws:iam::000000000000:user/bob",
+		AccountID:   "000000000000",
+		AccountName: "root",
+		Type:        "user",
+		Name:        "bob",
+	}, out)
+	require.NoError(t, err)
+
+	assert.Equal(t, `Account:  000000000000 (root)
+Type:     user
+User:     bob
+ARN:      arn:aws:iam::000000000000:user/bob
+`, out.String())
+}
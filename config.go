@@ -16,7 +16,10 @@
 package assumerole
 
 import (
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"time"
 
 	"github.com/ghodss/yaml"
@@ -38,7 +41,364 @@ type Config struct {
 
 	// ProfileNamePrefix is a prefix that will prepended to the role name to
 	// create the profile name under which the AWS configuration will be saved.
+	// "{account_name}" and "{account_id}" in the prefix are replaced with the
+	// name of the role's account in Accounts and its ID.
 	ProfileNamePrefix string `json:"profile_name_prefix"`
+
+	// Accounts maps account names to account IDs, so that accounts can be
+	// given by name.
+	Accounts map[string]string `json:"accounts"`
+
+	// OrganizationAccounts looks up account names in AWS Organizations, in
+	// addition to Accounts. The accounts are cached locally.
+	OrganizationAccounts bool `json:"organization_accounts"`
+
+	// Duration is the lifetime of the credentials requested from
+	// sts:AssumeRole. It must be between 15m and 12h, and can be no longer
+	// than the MaxSessionDuration of the role. Defaults to 1h.
+	Duration time.Duration `json:"duration"`
+
+	// Roles holds settings for individual roles, keyed by the role name or
+	// ARN as it is passed to the app.
+	Roles map[string]RoleConfig `json:"roles"`
+
+	// Tags are session tags that are set on every session.
+	Tags map[string]string `json:"tags"`
+
+	// TransitiveTagKeys are the keys of the session tags that are passed on
+	// to the sessions of roles assumed from this one.
+	TransitiveTagKeys []string `json:"transitive_tag_keys"`
+
+	// ContextTags adds session tags describing the project that assume-role
+	// is run from: the git repository and commit of the directory holding the
+	// config file.
+	ContextTags bool `json:"context_tags"`
+
+	// SetSourceIdentity sets the source identity of every session to the
+	// username of the current IAM user, so that actions can be traced back
+	// to them in CloudTrail even through role chains. Defaults to true.
+	SetSourceIdentity *bool `json:"set_source_identity"`
+
+	// SourceIdentity is a fixed source identity to set on every session,
+	// instead of the username.
+	SourceIdentity string `json:"source_identity"`
+
+	// WebIdentity configures getting credentials with a web identity token
+	// (e.g. an OIDC token from a CI system) instead of from an IAM user.
+	WebIdentity *WebIdentityConfig `json:"web_identity"`
+
+	// SAML configures getting credentials with a SAML assertion from an
+	// identity provider instead of from an IAM user.
+	SAML *SAMLConfig `json:"saml"`
+
+	// SourceProfile is the profile in the shared AWS config files with the
+	// credentials to assume roles with, instead of the default credentials.
+	SourceProfile string `json:"source_profile"`
+
+	// Region is the AWS region to use for STS and IAM, and for commands that
+	// are run. It also determines the partition (e.g. aws-cn or aws-us-gov)
+	// of the role ARNs that are built from names.
+	Region string `json:"region"`
+
+	// STSRegionalEndpoint uses the STS endpoint of the region rather than the
+	// global endpoint.
+	STSRegionalEndpoint bool `json:"sts_regional_endpoint"`
+
+	// FIPS uses FIPS endpoints.
+	FIPS bool `json:"use_fips_endpoint"`
+
+	// STSEndpointURL overrides the STS endpoint, e.g. for a VPC endpoint or a
+	// local stand-in like LocalStack.
+	STSEndpointURL string `json:"sts_endpoint_url"`
+
+	// IAMEndpointURL overrides the IAM endpoint.
+	IAMEndpointURL string `json:"iam_endpoint_url"`
+
+	// FederationEndpointURL overrides the federation endpoint that console
+	// sign-in URLs are made with.
+	FederationEndpointURL string `json:"federation_endpoint_url"`
+
+	// MFASession enables caching an MFA session from sts:GetSessionToken,
+	// which is used to assume roles that require MFA. MFA is then only
+	// prompted for when this session expires, rather than for every role.
+	MFASession bool `json:"mfa_session"`
+
+	// MFASessionDuration is the lifetime of the MFA session. It must be
+	// between 15m and 36h. Defaults to 12h.
+	MFASessionDuration time.Duration `json:"mfa_session_duration"`
+
+	// dir is the directory the config file was loaded from.
+	dir string
+}
+
+// RoleConfig is the configuration for a single role, overriding the top-level
+// configuration. When it is keyed by a short name rather than an ARN, it can
+// set the role that name refers to with either ARN, or AccountID and RoleName;
+// otherwise the name is combined with the role prefix.
+type RoleConfig struct {
+	// ARN is the ARN of the role.
+	ARN string `json:"arn"`
+
+	// AccountID is the ID of the AWS account the role is in.
+	AccountID string `json:"account"`
+
+	// RoleName is the name of the role in AccountID.
+	RoleName string `json:"role_name"`
+
+	// RoleSessionName is the session name for this role, instead of the
+	// username.
+	RoleSessionName string `json:"role_session_name"`
+
+	// Region is the AWS region that commands run with this role use.
+	Region string `json:"region"`
+
+	// MFA skips trying to assume the role without MFA, for roles that always
+	// require it.
+	MFA bool `json:"mfa"`
+
+	// Duration is the lifetime of the credentials for this role.
+	Duration time.Duration `json:"duration"`
+
+	// Via is a list of roles (names or ARNs) that are assumed in order before
+	// this role, each using the credentials of the one before it.
+	Via []string `json:"via"`
+
+	// Tags are session tags for this role, merged with the top-level tags.
+	Tags map[string]string `json:"tags"`
+
+	// TransitiveTagKeys are the keys of the session tags for this role that
+	// are passed on to the sessions of roles assumed from this one.
+	TransitiveTagKeys []string `json:"transitive_tag_keys"`
+
+	// ExternalID is the external ID required by the role's trust policy.
+	ExternalID string `json:"external_id"`
+
+	// Policy is an inline session policy that scopes down the permissions of
+	// the role, either as a JSON string or written out as YAML.
+	Policy json.RawMessage `json:"policy"`
+
+	// PolicyARNs are the ARNs of managed policies used as session policies.
+	PolicyARNs []string `json:"policy_arns"`
+}
+
+// WebIdentityConfig is the configuration for getting credentials with
+// sts:AssumeRoleWithWebIdentity. Exactly one of TokenFile, TokenEnv and
+// TokenProcess must be set.
+type WebIdentityConfig struct {
+	// RoleARN is the role that is assumed with the web identity token. Other
+	// roles are assumed from this one. If it is empty, the requested role is
+	// assumed with the web identity token directly.
+	RoleARN string `json:"role_arn"`
+
+	// RoleSessionName is the session name used for all roles, as there is no
+	// IAM username. Defaults to "assume-role".
+	RoleSessionName string `json:"role_session_name"`
+
+	// TokenFile is the path to a file holding the token.
+	TokenFile string `json:"token_file"`
+
+	// TokenEnv is the name of an environment variable holding the token.
+	TokenEnv string `json:"token_env"`
+
+	// TokenProcess is a shell command that prints the token.
+	TokenProcess string `json:"token_process"`
+}
+
+// SAMLConfig is the configuration for getting credentials with
+// sts:AssumeRoleWithSAML. Exactly one of AssertionFile and AssertionProcess
+// must be set.
+type SAMLConfig struct {
+	// RoleARN is the role that is assumed with the SAML assertion. Other
+	// roles are assumed from this one. If it is empty, the requested role is
+	// assumed with the SAML assertion directly.
+	RoleARN string `json:"role_arn"`
+
+	// RoleSessionName is the session name used for roles assumed from the
+	// SAML role. Defaults to "assume-role".
+	RoleSessionName string `json:"role_session_name"`
+
+	// AssertionFile is the path to a file holding the base64 encoded SAML
+	// assertion, or "-" to read it from stdin.
+	AssertionFile string `json:"assertion_file"`
+
+	// AssertionProcess is a shell command that prints the base64 encoded
+	// SAML assertion.
+	AssertionProcess string `json:"assertion_process"`
+}
+
+// usesIdentityProvider returns whether credentials are retrieved with a web
+// identity token or SAML assertion rather than with an IAM principal.
+func (c *Config) usesIdentityProvider() bool {
+	return c.WebIdentity != nil || c.SAML != nil
+}
+
+// setSourceIdentity returns whether the source identity is set to the
+// username of the current IAM user.
+func (c *Config) setSourceIdentity() bool {
+	return c.SetSourceIdentity == nil || *c.SetSourceIdentity
+}
+
+// identityProviderRoleARN returns the configured role to assume with a web
+// identity token or SAML assertion, if any.
+func (c *Config) identityProviderRoleARN() string {
+	switch {
+	case c.WebIdentity != nil:
+		return c.WebIdentity.RoleARN
+	case c.SAML != nil:
+		return c.SAML.RoleARN
+	}
+	return ""
+}
+
+// identityProviderSessionName returns the configured session name to use with
+// a web identity token or SAML assertion, if any.
+func (c *Config) identityProviderSessionName() string {
+	switch {
+	case c.WebIdentity != nil:
+		return c.WebIdentity.RoleSessionName
+	case c.SAML != nil:
+		return c.SAML.RoleSessionName
+	}
+	return ""
+}
+
+// durationValue is a time.Duration that can be read from configuration either
+// as a string (e.g. "1h30m") or as a number of seconds, like the AWS CLI's
+// duration_seconds.
+type durationValue time.Duration
+
+func (d *durationValue) UnmarshalJSON(b []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case float64:
+		*d = durationValue(v * float64(time.Second))
+	case string:
+		duration, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = durationValue(duration)
+	default:
+		return fmt.Errorf("invalid duration: %s", b)
+	}
+
+	return nil
+}
+
+// nanosecondsDurationValue is a time.Duration that can be read from
+// configuration either as a string (e.g. "15m") or as a number of
+// nanoseconds. refresh_before_expiry has always been read as nanoseconds, so
+// existing configs keep working.
+type nanosecondsDurationValue time.Duration
+
+func (d *nanosecondsDurationValue) UnmarshalJSON(b []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case float64:
+		*d = nanosecondsDurationValue(v)
+	case string:
+		duration, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = nanosecondsDurationValue(duration)
+	default:
+		return fmt.Errorf("invalid duration: %s", b)
+	}
+
+	return nil
+}
+
+// accountIDValue is an AWS account ID that can be written in the config file
+// as either a string or a number.
+type accountIDValue string
+
+// UnmarshalJSON reads an account ID from a JSON string or number.
+func (a *accountIDValue) UnmarshalJSON(b []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case float64:
+		// Account IDs are always 12 digits
+		*a = accountIDValue(fmt.Sprintf("%012.0f", v))
+	case string:
+		*a = accountIDValue(v)
+	default:
+		return fmt.Errorf("invalid account ID: %s", b)
+	}
+
+	return nil
+}
+
+// UnmarshalJSON reads the config, allowing durations to be written as strings.
+func (c *Config) UnmarshalJSON(b []byte) error {
+	type config Config
+
+	aux := struct {
+		*config
+		RefreshBeforeExpiry nanosecondsDurationValue  `json:"refresh_before_expiry"`
+		Duration            durationValue             `json:"duration"`
+		MFASessionDuration  durationValue             `json:"mfa_session_duration"`
+		Accounts            map[string]accountIDValue `json:"accounts"`
+	}{
+		config:              (*config)(c),
+		RefreshBeforeExpiry: nanosecondsDurationValue(c.RefreshBeforeExpiry),
+		Duration:            durationValue(c.Duration),
+		MFASessionDuration:  durationValue(c.MFASessionDuration),
+	}
+
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	c.RefreshBeforeExpiry = time.Duration(aux.RefreshBeforeExpiry)
+	c.Duration = time.Duration(aux.Duration)
+	c.MFASessionDuration = time.Duration(aux.MFASessionDuration)
+
+	if aux.Accounts != nil {
+		c.Accounts = make(map[string]string, len(aux.Accounts))
+		for name, accountID := range aux.Accounts {
+			c.Accounts[name] = string(accountID)
+		}
+	}
+
+	return nil
+}
+
+// UnmarshalJSON reads the role config, allowing durations to be written as
+// strings.
+func (c *RoleConfig) UnmarshalJSON(b []byte) error {
+	type roleConfig RoleConfig
+
+	aux := struct {
+		*roleConfig
+		Duration  durationValue  `json:"duration"`
+		AccountID accountIDValue `json:"account"`
+	}{
+		roleConfig: (*roleConfig)(c),
+		Duration:   durationValue(c.Duration),
+		AccountID:  accountIDValue(c.AccountID),
+	}
+
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	c.Duration = time.Duration(aux.Duration)
+	c.AccountID = string(aux.AccountID)
+
+	return nil
 }
 
 // SetDefaults sets any default values for unset variables.
@@ -46,6 +406,14 @@ func (c *Config) setDefaults() {
 	if c.RefreshBeforeExpiry == 0 {
 		c.RefreshBeforeExpiry = time.Minute * 15
 	}
+
+	if c.Duration == 0 {
+		c.Duration = time.Hour
+	}
+
+	if c.MFASessionDuration == 0 {
+		c.MFASessionDuration = 12 * time.Hour
+	}
 }
 
 // LoadConfig reads config values from a file and returns the config.
@@ -62,5 +430,7 @@ func LoadConfig(configFilePath string) (*Config, error) {
 		return nil, err
 	}
 
+	config.dir = filepath.Dir(configFilePath)
+
 	return &config, nil
 }
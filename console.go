@@ -0,0 +1,165 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package assumerole
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+// ConsoleOpts are the options for the console sign-in URL.
+type ConsoleOpts struct {
+	// Destination is the console URL to go to after signing in; if it is
+	// empty, the console home page of Region is used
+	Destination string
+
+	// SessionDuration is the lifetime of the console session; if it is zero,
+	// the federation endpoint's default of 12 hours is used
+	SessionDuration time.Duration
+
+	// Region is the region the console home page opens in, which also
+	// determines the partition of the federation endpoint
+	Region string
+}
+
+// federationEndpoints are the federation endpoints of the partitions.
+var federationEndpoints = map[string]string{
+	endpoints.AwsPartitionID:      "https://signin.aws.amazon.com/federation",
+	endpoints.AwsCnPartitionID:    "https://signin.amazonaws.cn/federation",
+	endpoints.AwsUsGovPartitionID: "https://signin.amazonaws-us-gov.com/federation",
+}
+
+// consoleURLs are the console home pages of the partitions.
+var consoleURLs = map[string]string{
+	endpoints.AwsPartitionID:      "https://console.aws.amazon.com/",
+	endpoints.AwsCnPartitionID:    "https://console.amazonaws.cn/",
+	endpoints.AwsUsGovPartitionID: "https://console.amazonaws-us-gov.com/",
+}
+
+// federationTimeout is how long to wait for the federation endpoint.
+const federationTimeout = 30 * time.Second
+
+// ConsoleURL returns a URL that signs in to the AWS console with the given
+// temporary credentials. The URL holds a sign-in token, and is valid for 15
+// minutes.
+func (app *App) ConsoleURL(credentials *TemporaryCredentials, opts ConsoleOpts) (string, error) {
+	if opts.SessionDuration != 0 && (opts.SessionDuration < minSessionDuration || opts.SessionDuration > maxSessionDuration) {
+		return "", fmt.Errorf("invalid console session duration %v: must be between %v and %v", opts.SessionDuration, minSessionDuration, maxSessionDuration)
+	}
+
+	partition := app.partition()
+	if opts.Region != "" {
+		partition = partitionForRegion(opts.Region)
+	}
+
+	endpoint := app.config.FederationEndpointURL
+	if endpoint == "" {
+		endpoint = federationEndpoints[partition]
+	}
+
+	token, err := getSigninToken(endpoint, credentials, opts.SessionDuration)
+	if err != nil {
+		return "", err
+	}
+
+	destination := opts.Destination
+	if destination == "" {
+		destination = consoleURLs[partition] + "console/home"
+		if opts.Region != "" {
+			destination += "?region=" + url.QueryEscape(opts.Region)
+		}
+	}
+
+	query := url.Values{}
+	query.Set("Action", "login")
+	query.Set("Destination", destination)
+	query.Set("SigninToken", token)
+
+	return federationURL(endpoint, query)
+}
+
+// federationURL returns the URL of a request to the federation endpoint,
+// adding query to the query the endpoint may already have.
+func federationURL(endpoint string, query url.Values) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid federation endpoint %s: %v", endpoint, err)
+	}
+
+	merged := u.Query()
+	for key, values := range query {
+		merged[key] = values
+	}
+	u.RawQuery = merged.Encode()
+
+	return u.String(), nil
+}
+
+// getSigninToken exchanges temporary credentials for a sign-in token at the
+// federation endpoint.
+func getSigninToken(endpoint string, credentials *TemporaryCredentials, duration time.Duration) (string, error) {
+	session, err := json.Marshal(map[string]string{
+		"sessionId":    credentials.AccessKeyID,
+		"sessionKey":   credentials.SecretAccessKey,
+		"sessionToken": credentials.SessionToken,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("Action", "getSigninToken")
+	query.Set("Session", string(session))
+	if duration != 0 {
+		query.Set("SessionDuration", strconv.Itoa(int(duration.Seconds())))
+	}
+
+	signinTokenURL, err := federationURL(endpoint, query)
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Timeout: federationTimeout}
+
+	resp, err := client.Get(signinTokenURL)
+	if err != nil {
+		return "", fmt.Errorf("could not get a sign-in token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not get a sign-in token: %s returned %s", endpoint, resp.Status)
+	}
+
+	var body struct {
+		SigninToken string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("could not parse the sign-in token: %v", err)
+	}
+
+	if body.SigninToken == "" {
+		return "", fmt.Errorf("could not get a sign-in token: %s returned no token", endpoint)
+	}
+
+	return body.SigninToken, nil
+}
@@ -0,0 +1,93 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package assumerole
+
+import (
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Session tag keys for the context of the project.
+const (
+	gitRepositoryTagKey = "git-repository"
+	gitCommitTagKey     = "git-commit"
+)
+
+// Session tag values can be at most 256 characters long, and only contain
+// letters, numbers, spaces and the characters _.:/=+-@.
+const maxTagValueLength = 256
+
+var invalidTagValueChars = regexp.MustCompile(`[^\p{L}\p{Z}\p{N}_.:/=+\-@]`)
+
+// contextTags returns session tags describing the project in dir: the git
+// repository it belongs to, and the commit that is checked out. If dir is not
+// in a git repository, there are no tags.
+func contextTags(dir string) map[string]string {
+	tags := make(map[string]string)
+
+	if dir == "" {
+		return tags
+	}
+
+	if repository, err := git(dir, "config", "--get", "remote.origin.url"); err == nil && repository != "" {
+		tags[gitRepositoryTagKey] = tagValue(withoutUserInfo(repository))
+	}
+
+	if commit, err := git(dir, "rev-parse", "HEAD"); err == nil && commit != "" {
+		tags[gitCommitTagKey] = tagValue(commit)
+	}
+
+	return tags
+}
+
+// git runs a git command in dir and returns its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// withoutUserInfo removes any credentials from a repository URL, so that they
+// don't end up in CloudTrail.
+func withoutUserInfo(repository string) string {
+	u, err := url.Parse(repository)
+	if err != nil || u.User == nil {
+		return repository
+	}
+
+	u.User = nil
+	return u.String()
+}
+
+// tagValue makes a string safe to use as a session tag value. Long values
+// are cut off at a whole character, as AWS counts characters rather than
+// bytes and rejects invalid UTF-8.
+func tagValue(value string) string {
+	value = invalidTagValueChars.ReplaceAllString(value, "_")
+
+	if runes := []rune(value); len(runes) > maxTagValueLength {
+		value = string(runes[:maxTagValueLength])
+	}
+
+	return value
+}
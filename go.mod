@@ -3,7 +3,7 @@ module github.com/uber/assume-role-cli
 go 1.13
 
 require (
-	github.com/aws/aws-sdk-go v1.25.8
+	github.com/aws/aws-sdk-go v1.44.0
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/ghodss/yaml v1.0.0
 	github.com/go-ini/ini v1.48.0
@@ -14,8 +14,5 @@ require (
 	github.com/smartystreets/goconvey v0.0.0-20190731233626-505e41936337 // indirect
 	github.com/stretchr/testify v1.4.0
 	golang.org/x/crypto v0.0.0-20191002192127-34f69633bfdc
-	golang.org/x/net v0.0.0-20191007182048-72f939374954 // indirect
-	golang.org/x/sys v0.0.0-20191008105621-543471e840be // indirect
 	gopkg.in/ini.v1 v1.48.0 // indirect
-	gopkg.in/yaml.v2 v2.2.4 // indirect
 )
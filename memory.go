@@ -0,0 +1,120 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package assumerole
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryAWSConfig is an AWSConfigProvider that keeps the profiles and
+// credentials in memory instead of ~/.aws, for the agent. It is safe for
+// concurrent use.
+type MemoryAWSConfig struct {
+	mu          sync.Mutex
+	profiles    map[string]ProfileConfiguration
+	credentials map[string]TemporaryCredentials
+}
+
+// NewMemoryAWSConfig returns an empty MemoryAWSConfig.
+func NewMemoryAWSConfig() *MemoryAWSConfig {
+	return &MemoryAWSConfig{
+		profiles:    make(map[string]ProfileConfiguration),
+		credentials: make(map[string]TemporaryCredentials),
+	}
+}
+
+// GetProfile returns a profile, which is empty if it doesn't exist.
+func (c *MemoryAWSConfig) GetProfile(profileName string) (*ProfileConfiguration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	profile := c.profiles[profileName]
+	return &profile, nil
+}
+
+// SetProfile stores a profile.
+func (c *MemoryAWSConfig) SetProfile(profileName string, profile *ProfileConfiguration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.profiles[profileName] = *profile
+	return nil
+}
+
+// ListProfiles returns the names of the profiles with an expiration, sorted by
+// name.
+func (c *MemoryAWSConfig) ListProfiles() ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var names []string
+
+	for name, profile := range c.profiles {
+		if !profile.Expires.IsZero() {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// DeleteProfile removes a profile.
+func (c *MemoryAWSConfig) DeleteProfile(profileName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.profiles, profileName)
+	return nil
+}
+
+// GetCredentials returns the credentials of a profile, which are empty if
+// there are none, with the expiry from the profile.
+func (c *MemoryAWSConfig) GetCredentials(profileName string) (*TemporaryCredentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	creds := c.credentials[profileName]
+	creds.Expires = c.profiles[profileName].Expires
+
+	return &creds, nil
+}
+
+// SetCredentials stores the credentials of a profile, and sets the expiry of
+// the profile.
+func (c *MemoryAWSConfig) SetCredentials(profileName string, creds *TemporaryCredentials) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.credentials[profileName] = *creds
+
+	profile := c.profiles[profileName]
+	profile.Expires = creds.Expires
+	c.profiles[profileName] = profile
+
+	return nil
+}
+
+// DeleteCredentials removes the credentials of a profile.
+func (c *MemoryAWSConfig) DeleteCredentials(profileName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.credentials, profileName)
+	return nil
+}
@@ -23,6 +23,7 @@ import (
 	gomock "github.com/golang/mock/gomock"
 	assumerole_cli "github.com/uber/assume-role-cli"
 	reflect "reflect"
+	time "time"
 )
 
 // MockAWSProvider is a mock of AWSProvider interface
@@ -49,29 +50,55 @@ func (m *MockAWSProvider) EXPECT() *MockAWSProviderMockRecorder {
 }
 
 // AssumeRole mocks base method
-func (m *MockAWSProvider) AssumeRole(arg0, arg1 string) (*assumerole_cli.TemporaryCredentials, error) {
-	ret := m.ctrl.Call(m, "AssumeRole", arg0, arg1)
+func (m *MockAWSProvider) AssumeRole(arg0 assumerole_cli.AssumeRoleInput) (*assumerole_cli.TemporaryCredentials, error) {
+	ret := m.ctrl.Call(m, "AssumeRole", arg0)
 	ret0, _ := ret[0].(*assumerole_cli.TemporaryCredentials)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AssumeRole indicates an expected call of AssumeRole
-func (mr *MockAWSProviderMockRecorder) AssumeRole(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssumeRole", reflect.TypeOf((*MockAWSProvider)(nil).AssumeRole), arg0, arg1)
+func (mr *MockAWSProviderMockRecorder) AssumeRole(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssumeRole", reflect.TypeOf((*MockAWSProvider)(nil).AssumeRole), arg0)
 }
 
 // AssumeRoleWithMFA mocks base method
-func (m *MockAWSProvider) AssumeRoleWithMFA(arg0, arg1, arg2, arg3 string) (*assumerole_cli.TemporaryCredentials, error) {
-	ret := m.ctrl.Call(m, "AssumeRoleWithMFA", arg0, arg1, arg2, arg3)
+func (m *MockAWSProvider) AssumeRoleWithMFA(arg0 assumerole_cli.AssumeRoleInput, arg1, arg2 string) (*assumerole_cli.TemporaryCredentials, error) {
+	ret := m.ctrl.Call(m, "AssumeRoleWithMFA", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*assumerole_cli.TemporaryCredentials)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AssumeRoleWithMFA indicates an expected call of AssumeRoleWithMFA
-func (mr *MockAWSProviderMockRecorder) AssumeRoleWithMFA(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssumeRoleWithMFA", reflect.TypeOf((*MockAWSProvider)(nil).AssumeRoleWithMFA), arg0, arg1, arg2, arg3)
+func (mr *MockAWSProviderMockRecorder) AssumeRoleWithMFA(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssumeRoleWithMFA", reflect.TypeOf((*MockAWSProvider)(nil).AssumeRoleWithMFA), arg0, arg1, arg2)
+}
+
+// AssumeRoleWithSAML mocks base method
+func (m *MockAWSProvider) AssumeRoleWithSAML(arg0 assumerole_cli.AssumeRoleInput, arg1, arg2 string) (*assumerole_cli.TemporaryCredentials, error) {
+	ret := m.ctrl.Call(m, "AssumeRoleWithSAML", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*assumerole_cli.TemporaryCredentials)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AssumeRoleWithSAML indicates an expected call of AssumeRoleWithSAML
+func (mr *MockAWSProviderMockRecorder) AssumeRoleWithSAML(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssumeRoleWithSAML", reflect.TypeOf((*MockAWSProvider)(nil).AssumeRoleWithSAML), arg0, arg1, arg2)
+}
+
+// AssumeRoleWithWebIdentity mocks base method
+func (m *MockAWSProvider) AssumeRoleWithWebIdentity(arg0 assumerole_cli.AssumeRoleInput, arg1 string) (*assumerole_cli.TemporaryCredentials, error) {
+	ret := m.ctrl.Call(m, "AssumeRoleWithWebIdentity", arg0, arg1)
+	ret0, _ := ret[0].(*assumerole_cli.TemporaryCredentials)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AssumeRoleWithWebIdentity indicates an expected call of AssumeRoleWithWebIdentity
+func (mr *MockAWSProviderMockRecorder) AssumeRoleWithWebIdentity(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AssumeRoleWithWebIdentity", reflect.TypeOf((*MockAWSProvider)(nil).AssumeRoleWithWebIdentity), arg0, arg1)
 }
 
 // CurrentPrincipalARN mocks base method
@@ -87,6 +114,32 @@ func (mr *MockAWSProviderMockRecorder) CurrentPrincipalARN() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CurrentPrincipalARN", reflect.TypeOf((*MockAWSProvider)(nil).CurrentPrincipalARN))
 }
 
+// GetSessionToken mocks base method
+func (m *MockAWSProvider) GetSessionToken(arg0 time.Duration, arg1, arg2 string) (*assumerole_cli.TemporaryCredentials, error) {
+	ret := m.ctrl.Call(m, "GetSessionToken", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*assumerole_cli.TemporaryCredentials)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessionToken indicates an expected call of GetSessionToken
+func (mr *MockAWSProviderMockRecorder) GetSessionToken(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionToken", reflect.TypeOf((*MockAWSProvider)(nil).GetSessionToken), arg0, arg1, arg2)
+}
+
+// ListAccounts mocks base method
+func (m *MockAWSProvider) ListAccounts() (map[string]string, error) {
+	ret := m.ctrl.Call(m, "ListAccounts")
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccounts indicates an expected call of ListAccounts
+func (mr *MockAWSProviderMockRecorder) ListAccounts() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccounts", reflect.TypeOf((*MockAWSProvider)(nil).ListAccounts))
+}
+
 // MFADevices mocks base method
 func (m *MockAWSProvider) MFADevices() ([]string, error) {
 	ret := m.ctrl.Call(m, "MFADevices")
@@ -100,6 +153,19 @@ func (mr *MockAWSProviderMockRecorder) MFADevices() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MFADevices", reflect.TypeOf((*MockAWSProvider)(nil).MFADevices))
 }
 
+// RoleMaxSessionDuration mocks base method
+func (m *MockAWSProvider) RoleMaxSessionDuration(arg0 string) (time.Duration, error) {
+	ret := m.ctrl.Call(m, "RoleMaxSessionDuration", arg0)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RoleMaxSessionDuration indicates an expected call of RoleMaxSessionDuration
+func (mr *MockAWSProviderMockRecorder) RoleMaxSessionDuration(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RoleMaxSessionDuration", reflect.TypeOf((*MockAWSProvider)(nil).RoleMaxSessionDuration), arg0)
+}
+
 // Username mocks base method
 func (m *MockAWSProvider) Username() (string, error) {
 	ret := m.ctrl.Call(m, "Username")
@@ -113,6 +179,18 @@ func (mr *MockAWSProviderMockRecorder) Username() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Username", reflect.TypeOf((*MockAWSProvider)(nil).Username))
 }
 
+// WithCredentials mocks base method
+func (m *MockAWSProvider) WithCredentials(arg0 *assumerole_cli.TemporaryCredentials) assumerole_cli.AWSProvider {
+	ret := m.ctrl.Call(m, "WithCredentials", arg0)
+	ret0, _ := ret[0].(assumerole_cli.AWSProvider)
+	return ret0
+}
+
+// WithCredentials indicates an expected call of WithCredentials
+func (mr *MockAWSProviderMockRecorder) WithCredentials(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithCredentials", reflect.TypeOf((*MockAWSProvider)(nil).WithCredentials), arg0)
+}
+
 // MockAWSConfigProvider is a mock of AWSConfigProvider interface
 type MockAWSConfigProvider struct {
 	ctrl     *gomock.Controller
@@ -136,6 +214,30 @@ func (m *MockAWSConfigProvider) EXPECT() *MockAWSConfigProviderMockRecorder {
 	return m.recorder
 }
 
+// DeleteCredentials mocks base method
+func (m *MockAWSConfigProvider) DeleteCredentials(arg0 string) error {
+	ret := m.ctrl.Call(m, "DeleteCredentials", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCredentials indicates an expected call of DeleteCredentials
+func (mr *MockAWSConfigProviderMockRecorder) DeleteCredentials(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCredentials", reflect.TypeOf((*MockAWSConfigProvider)(nil).DeleteCredentials), arg0)
+}
+
+// DeleteProfile mocks base method
+func (m *MockAWSConfigProvider) DeleteProfile(arg0 string) error {
+	ret := m.ctrl.Call(m, "DeleteProfile", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteProfile indicates an expected call of DeleteProfile
+func (mr *MockAWSConfigProviderMockRecorder) DeleteProfile(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteProfile", reflect.TypeOf((*MockAWSConfigProvider)(nil).DeleteProfile), arg0)
+}
+
 // GetCredentials mocks base method
 func (m *MockAWSConfigProvider) GetCredentials(arg0 string) (*assumerole_cli.TemporaryCredentials, error) {
 	ret := m.ctrl.Call(m, "GetCredentials", arg0)
@@ -162,6 +264,19 @@ func (mr *MockAWSConfigProviderMockRecorder) GetProfile(arg0 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfile", reflect.TypeOf((*MockAWSConfigProvider)(nil).GetProfile), arg0)
 }
 
+// ListProfiles mocks base method
+func (m *MockAWSConfigProvider) ListProfiles() ([]string, error) {
+	ret := m.ctrl.Call(m, "ListProfiles")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListProfiles indicates an expected call of ListProfiles
+func (mr *MockAWSConfigProviderMockRecorder) ListProfiles() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListProfiles", reflect.TypeOf((*MockAWSConfigProvider)(nil).ListProfiles))
+}
+
 // SetCredentials mocks base method
 func (m *MockAWSConfigProvider) SetCredentials(arg0 string, arg1 *assumerole_cli.TemporaryCredentials) error {
 	ret := m.ctrl.Call(m, "SetCredentials", arg0, arg1)
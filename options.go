@@ -51,6 +51,25 @@ func WithAWSConfig(awsConfig AWSConfigProvider) Option {
 	}
 }
 
+// WithAccountsCacheFile allows you to change where the accounts from AWS
+// Organizations are cached.
+func WithAccountsCacheFile(path string) Option {
+	return func(app *App) error {
+		app.accountsCacheFile = path
+		return nil
+	}
+}
+
+// WithoutAccountsCacheFile keeps the accounts from AWS Organizations in memory
+// only, e.g. when the cache is kept by the agent, so that nothing is written
+// to ~/.aws.
+func WithoutAccountsCacheFile() Option {
+	return func(app *App) error {
+		app.noAccountsCacheFile = true
+		return nil
+	}
+}
+
 // WithClock allows you to specify a custom clock implementation (for tests).
 func WithClock(clock Clock) Option {
 	return func(app *App) error {
@@ -68,6 +87,17 @@ func WithConfig(config *Config) Option {
 	}
 }
 
+// WithCredentialsSource allows you to set where the credentials that roles
+// are assumed with come from, as returned by CredentialsSource, when AWS is
+// called by another process such as the agent. It is part of the names of the
+// profiles that credentials are cached under.
+func WithCredentialsSource(source string) Option {
+	return func(app *App) error {
+		app.credentialsSourceOverride = &source
+		return nil
+	}
+}
+
 // WithStderr allows you to pass a custom stderr.
 func WithStderr(stderr io.Writer) Option {
 	return func(app *App) error {
@@ -0,0 +1,184 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package assumerole
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// refreshRetryInterval is how long a Refresher waits to try again after
+// failing to refresh the credentials.
+const refreshRetryInterval = time.Minute
+
+// Refresher keeps the credentials of a role fresh for long-running processes,
+// assuming the role again once they are within refresh_before_expiry of
+// expiring. It is safe for concurrent use.
+type Refresher struct {
+	app    *App
+	params AssumeRoleParameters
+
+	// noPrompts makes refreshing fail rather than prompt for MFA tokens or
+	// SAML assertions
+	noPrompts bool
+
+	// promptErr is set once refreshing failed because it needed a prompt;
+	// until the credentials are refreshed in another terminal, only the
+	// cache is checked
+	promptErr *PromptRequiredError
+
+	// mu also serializes the calls to the app, which prompts for MFA tokens
+	mu          sync.Mutex
+	credentials *TemporaryCredentials
+}
+
+// NewRefresher returns a Refresher for the role assumed with params, starting
+// with the credentials it was assumed with, if any.
+func (app *App) NewRefresher(params AssumeRoleParameters, credentials *TemporaryCredentials) *Refresher {
+	// Refreshing goes through the cache, so that other assume-role processes
+	// share the credentials
+	params.ForceRefresh = false
+
+	return &Refresher{
+		app:         app,
+		params:      params,
+		credentials: credentials,
+	}
+}
+
+// DisablePrompts makes refreshing the credentials fail with an error when the
+// user would have to be prompted, e.g. for an MFA token, for when a command
+// that shares the terminal is running.
+func (r *Refresher) DisablePrompts() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.noPrompts = true
+}
+
+// Credentials returns the current credentials, assuming the role again first
+// if they need refreshing.
+func (r *Refresher) Credentials() (*TemporaryCredentials, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.credentials == nil || r.app.credentialsExpired(r.credentials.Expires) {
+		if r.credentials != nil {
+			// A command is usually running by now, so make it clear what an
+			// MFA prompt is for
+			r.app.promptNotice = fmt.Sprintf("assume-role: the credentials of %s expire at %s and need to be refreshed.", r.params.UserRole, r.credentials.Expires.Local().Format("15:04:05"))
+			defer func() { r.app.promptNotice = "" }()
+		}
+
+		r.app.noPrompts = r.noPrompts
+		r.app.cacheOnly = r.promptErr != nil
+		defer func() {
+			r.app.noPrompts = false
+			r.app.cacheOnly = false
+		}()
+
+		credentials, err := r.app.AssumeRole(r.params)
+		if err == errCachedCredentialsExpired {
+			return nil, r.promptErr
+		}
+		if promptErr := promptRequiredError(err); promptErr != nil {
+			r.promptErr = promptErr
+			return nil, promptErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.credentials = credentials
+		r.promptErr = nil
+	}
+
+	return r.credentials, nil
+}
+
+// RoleARN returns the ARN of the role that the credentials are for.
+func (r *Refresher) RoleARN() (string, error) {
+	if r.params.Account != "" {
+		return r.app.roleInAccount(r.params.Account, r.params.UserRole)
+	}
+
+	return r.app.roleARN(r.params.UserRole)
+}
+
+// Expires returns when the current credentials expire, without refreshing
+// them.
+func (r *Refresher) Expires() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.credentials == nil {
+		return time.Time{}
+	}
+
+	return r.credentials.Expires
+}
+
+// NextRefresh returns when the credentials will next be refreshed.
+func (r *Refresher) NextRefresh() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.credentials == nil {
+		return r.app.clock.Now()
+	}
+
+	return r.credentials.Expires.Add(-r.app.config.RefreshBeforeExpiry)
+}
+
+// Run refreshes the credentials ahead of expiry until stop is closed, so that
+// callers of Credentials don't wait for AWS or an MFA prompt. The new
+// credentials are passed to onRefresh, if it is given. Errors are passed to
+// onError, and refreshing is tried again after a minute. When refreshing
+// needs a prompt while prompts are disabled, the PromptRequiredError is only
+// passed to onError once, and the cache is checked every minute instead of
+// calling AWS, until the credentials are refreshed in another terminal.
+func (r *Refresher) Run(stop <-chan struct{}, onRefresh func(*TemporaryCredentials), onError func(error)) {
+	wait := r.NextRefresh().Sub(r.app.clock.Now())
+	reportedPrompt := false
+
+	for {
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		credentials, err := r.Credentials()
+		if err != nil {
+			if !IsPromptRequiredError(err) || !reportedPrompt {
+				onError(err)
+			}
+			reportedPrompt = IsPromptRequiredError(err)
+			wait = refreshRetryInterval
+			continue
+		}
+		reportedPrompt = false
+
+		if onRefresh != nil {
+			onRefresh(credentials)
+		}
+
+		wait = r.NextRefresh().Sub(r.app.clock.Now())
+	}
+}
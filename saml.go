@@ -0,0 +1,128 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package assumerole
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// samlRoleAttribute is the name of the SAML attribute that lists the roles a
+// user can assume, as "role ARN,principal ARN" pairs.
+const samlRoleAttribute = "https://aws.amazon.com/SAML/Attributes/Role"
+
+// SAMLRole is a role that can be assumed with a SAML assertion, along with the
+// ARN of the SAML provider in IAM that issued it.
+type SAMLRole struct {
+	RoleARN      string
+	PrincipalARN string
+}
+
+// samlAttribute is an attribute from the AttributeStatement of a SAML
+// assertion.
+type samlAttribute struct {
+	Name   string   `xml:"Name,attr"`
+	Values []string `xml:"AttributeValue"`
+}
+
+// samlRoles parses a base64 encoded SAML assertion and returns the roles in
+// its Role attribute.
+func samlRoles(assertion string) ([]SAMLRole, error) {
+	b, err := base64.StdEncoding.DecodeString(assertion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML assertion: %v", err)
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(b))
+
+	var roles []SAMLRole
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid SAML assertion: %v", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "Attribute" {
+			continue
+		}
+
+		var attribute samlAttribute
+		if err := decoder.DecodeElement(&attribute, &start); err != nil {
+			return nil, fmt.Errorf("invalid SAML assertion: %v", err)
+		}
+
+		if attribute.Name != samlRoleAttribute {
+			continue
+		}
+
+		for _, value := range attribute.Values {
+			role, err := parseSAMLRole(value)
+			if err != nil {
+				return nil, err
+			}
+			roles = append(roles, role)
+		}
+	}
+
+	if len(roles) == 0 {
+		return nil, errors.New("SAML assertion doesn't contain any roles")
+	}
+
+	return roles, nil
+}
+
+// parseSAMLRole parses a value of the Role attribute. Identity providers put
+// the role and principal ARNs in either order.
+func parseSAMLRole(value string) (SAMLRole, error) {
+	parts := strings.Split(strings.TrimSpace(value), ",")
+	if len(parts) != 2 {
+		return SAMLRole{}, fmt.Errorf("invalid role in SAML assertion: %q", value)
+	}
+
+	role := SAMLRole{
+		RoleARN:      strings.TrimSpace(parts[0]),
+		PrincipalARN: strings.TrimSpace(parts[1]),
+	}
+
+	if strings.Contains(role.RoleARN, ":saml-provider/") {
+		role.RoleARN, role.PrincipalARN = role.PrincipalARN, role.RoleARN
+	}
+
+	if !isValidARN(role.RoleARN) || !isValidARN(role.PrincipalARN) {
+		return SAMLRole{}, fmt.Errorf("invalid role in SAML assertion: %q", value)
+	}
+
+	return role, nil
+}
+
+// formatSAMLRoles lists SAML roles one per line, for error messages.
+func formatSAMLRoles(roles []SAMLRole) string {
+	var lines []string
+	for _, role := range roles {
+		lines = append(lines, fmt.Sprintf("  %s (%s)", role.RoleARN, role.PrincipalARN))
+	}
+	return strings.Join(lines, "\n")
+}
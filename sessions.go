@@ -0,0 +1,149 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package assumerole
+
+import (
+	"strings"
+	"time"
+)
+
+// CachedSession is a role session that assume-role has cached the
+// credentials of in a profile.
+type CachedSession struct {
+	// ProfileName is the name of the profile the credentials are cached under
+	ProfileName string
+
+	// Profile is the configuration of the profile
+	Profile *ProfileConfiguration
+
+	// Remaining is the time until the credentials expire; it is negative once
+	// they have expired
+	Remaining time.Duration
+
+	// NeedsRefresh is set once the credentials are within
+	// refresh_before_expiry of expiring, so that they will be refreshed the
+	// next time they are used
+	NeedsRefresh bool
+}
+
+// CachedSessions returns the sessions that assume-role has cached
+// credentials for, sorted by profile name.
+func (app *App) CachedSessions() ([]CachedSession, error) {
+	names, err := app.awsConfig.ListProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []CachedSession
+
+	for _, name := range names {
+		profile, err := app.awsConfig.GetProfile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, CachedSession{
+			ProfileName:  name,
+			Profile:      profile,
+			Remaining:    profile.Expires.Sub(app.clock.Now()),
+			NeedsRefresh: app.credentialsExpired(profile.Expires),
+		})
+	}
+
+	return sessions, nil
+}
+
+// ClearSessions removes the cached credentials of a role, given by name or ARN
+// as for AssumeRole, and optionally an account. It returns the names of the
+// profiles that were removed.
+func (app *App) ClearSessions(userRole string, account string) ([]string, error) {
+	if account != "" {
+		roleARN, err := app.roleInAccount(account, userRole)
+		if err != nil {
+			return nil, err
+		}
+		userRole = roleARN
+	}
+
+	roleARN, err := app.roleARN(userRole)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.removeSessions(func(session CachedSession) bool {
+		return session.Profile.RoleARN == roleARN
+	})
+}
+
+// ClearAllSessions removes the cached credentials of every role, returning the
+// names of the profiles that were removed. The cached MFA session is kept, so
+// that assuming a role again doesn't need an MFA token.
+func (app *App) ClearAllSessions() ([]string, error) {
+	return app.removeSessions(func(session CachedSession) bool {
+		return !isMFASessionProfile(session.ProfileName)
+	})
+}
+
+// ClearMFASessions removes the cached MFA sessions, returning the names of the
+// profiles that were removed.
+func (app *App) ClearMFASessions() ([]string, error) {
+	return app.removeSessions(func(session CachedSession) bool {
+		return isMFASessionProfile(session.ProfileName)
+	})
+}
+
+// isMFASessionProfile returns whether a profile holds a cached MFA session
+// rather than the credentials of a role.
+func isMFASessionProfile(profileName string) bool {
+	return strings.HasPrefix(profileName, mfaSessionProfilePrefix)
+}
+
+// PruneSessions removes the cached credentials that have expired, returning
+// the names of the profiles that were removed.
+func (app *App) PruneSessions() ([]string, error) {
+	return app.removeSessions(func(session CachedSession) bool {
+		return session.Remaining <= 0
+	})
+}
+
+// removeSessions removes the cached sessions that match, and returns the
+// names of their profiles. Only profiles written by assume-role are ever
+// removed.
+func (app *App) removeSessions(match func(session CachedSession) bool) ([]string, error) {
+	sessions, err := app.CachedSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+
+	for _, session := range sessions {
+		if !match(session) {
+			continue
+		}
+
+		if err := app.awsConfig.DeleteCredentials(session.ProfileName); err != nil {
+			return removed, err
+		}
+		if err := app.awsConfig.DeleteProfile(session.ProfileName); err != nil {
+			return removed, err
+		}
+
+		removed = append(removed, session.ProfileName)
+	}
+
+	return removed, nil
+}
@@ -17,8 +17,14 @@ package assumerole
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/go-ini/ini"
@@ -30,6 +36,114 @@ func isValidARN(str string) bool {
 	return err == nil
 }
 
+// isAssumedRoleARN returns whether str is the ARN of an assumed role session,
+// in any partition.
+func isAssumedRoleARN(str string) bool {
+	parsed, err := arn.Parse(str)
+	if err != nil {
+		return false
+	}
+
+	return parsed.Service == "sts" && strings.HasPrefix(parsed.Resource, "assumed-role/")
+}
+
+// shorterSessionDuration returns the next whole hour below the given
+// duration, but never less than one hour.
+func shorterSessionDuration(duration time.Duration) time.Duration {
+	shorter := duration.Truncate(time.Hour)
+	if shorter == duration {
+		shorter -= time.Hour
+	}
+
+	if shorter < minRoleMaxDuration {
+		return minRoleMaxDuration
+	}
+
+	return shorter
+}
+
+// sessionHash returns a short hash of the session policies and tags of the
+// input, of the source of the credentials they are assumed with and of the
+// roles the session is assumed via, or the empty string if there are none. It
+// is used to keep apart the cached credentials of sessions that only differ
+// in those.
+func sessionHash(input AssumeRoleInput, source string, via []string) string {
+	if input.Policy == "" && len(input.PolicyARNs) == 0 && len(input.Tags) == 0 && source == "" && len(via) == 0 {
+		return ""
+	}
+
+	policyARNs := append([]string{}, input.PolicyARNs...)
+	sort.Strings(policyARNs)
+
+	tagKeys := make([]string, 0, len(input.Tags))
+	for key := range input.Tags {
+		tagKeys = append(tagKeys, key)
+	}
+	sort.Strings(tagKeys)
+
+	h := sha256.New()
+	if source != "" {
+		fmt.Fprintf(h, "%s\n", source)
+	}
+	for _, roleARN := range via {
+		fmt.Fprintf(h, "via=%s\n", roleARN)
+	}
+	fmt.Fprintf(h, "policy=%s\n", input.Policy)
+	for _, policyARN := range policyARNs {
+		fmt.Fprintf(h, "policy_arn=%s\n", policyARN)
+	}
+	for _, key := range tagKeys {
+		fmt.Fprintf(h, "tag=%s=%s\n", key, input.Tags[key])
+	}
+	for _, key := range input.TransitiveTagKeys {
+		fmt.Fprintf(h, "transitive_tag_key=%s\n", key)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:8]
+}
+
+// withoutTags returns the tags and transitive tag keys without the given keys.
+func withoutTags(tags map[string]string, transitiveTagKeys []string, keys []string) (map[string]string, []string) {
+	if len(keys) == 0 {
+		return tags, transitiveTagKeys
+	}
+
+	remove := make(map[string]bool)
+	for _, key := range keys {
+		remove[key] = true
+	}
+
+	var remainingTags map[string]string
+	for key, value := range tags {
+		if !remove[key] {
+			if remainingTags == nil {
+				remainingTags = make(map[string]string)
+			}
+			remainingTags[key] = value
+		}
+	}
+
+	var remainingKeys []string
+	for _, key := range transitiveTagKeys {
+		if !remove[key] {
+			remainingKeys = append(remainingKeys, key)
+		}
+	}
+
+	return remainingTags, remainingKeys
+}
+
+// policyDocument returns the policy document from the configuration, which is
+// either a JSON string or a policy written out as YAML.
+func policyDocument(raw json.RawMessage) (string, error) {
+	var document string
+	if err := json.Unmarshal(raw, &document); err == nil {
+		return document, nil
+	}
+
+	return string(raw), nil
+}
+
 func readInput(in *bufio.Reader) (string, error) {
 	val, err := in.ReadString('\n')
 	return strings.TrimSpace(val), err
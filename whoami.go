@@ -0,0 +1,127 @@
+/*
+ *  Copyright (c) 2018 Uber Technologies, Inc.
+ *
+ *     Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package assumerole
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+)
+
+// Principal describes the IAM principal that AWS is called as.
+type Principal struct {
+	// ARN is the ARN of the principal
+	ARN string
+
+	// AccountID is the ID of the AWS account of the principal
+	AccountID string
+
+	// AccountName is the name of the account, if it is known from the
+	// accounts configuration or AWS Organizations
+	AccountName string
+
+	// Type is the type of principal: "user", "assumed-role",
+	// "federated-user" or "root"
+	Type string
+
+	// Name is the name of the user or role
+	Name string
+
+	// SessionName is the session name of an assumed role or federated user
+	SessionName string
+
+	// Session is the session cached by assume-role that the credentials
+	// belong to, if they were cached by assume-role
+	Session *CachedSession
+}
+
+// WhoAmI returns the current IAM principal. If accessKeyID is the access key
+// of credentials cached by assume-role (e.g. because assume-role set up the
+// environment), the cached session is returned along with it.
+func (app *App) WhoAmI(accessKeyID string) (*Principal, error) {
+	principalARN, err := app.aws.CurrentPrincipalARN()
+	if err != nil {
+		return nil, err
+	}
+
+	principal, err := parsePrincipalARN(principalARN)
+	if err != nil {
+		return nil, err
+	}
+
+	if name := app.accountName(principal.AccountID); name != principal.AccountID {
+		principal.AccountName = name
+	}
+
+	if accessKeyID == "" {
+		return principal, nil
+	}
+
+	sessions, err := app.CachedSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		creds, err := app.awsConfig.GetCredentials(sessions[i].ProfileName)
+		if err != nil {
+			return nil, err
+		}
+
+		if creds.AccessKeyID == accessKeyID {
+			principal.Session = &sessions[i]
+			break
+		}
+	}
+
+	return principal, nil
+}
+
+// parsePrincipalARN parses the ARN of an IAM principal as returned by
+// sts:GetCallerIdentity, e.g.
+// "arn:aws:sts::123456789012:assumed-role/admin/bob".
+func parsePrincipalARN(principalARN string) (*Principal, error) {
+	parsedARN, err := arn.Parse(principalARN)
+	if err != nil {
+		return nil, err
+	}
+
+	principal := &Principal{
+		ARN:       principalARN,
+		AccountID: parsedARN.AccountID,
+	}
+
+	parts := strings.Split(parsedARN.Resource, "/")
+	principal.Type = parts[0]
+
+	switch principal.Type {
+	case "assumed-role":
+		if len(parts) >= 3 {
+			principal.Name = parts[1]
+			principal.SessionName = parts[len(parts)-1]
+		}
+
+	case "federated-user":
+		principal.Name = parts[len(parts)-1]
+		principal.SessionName = principal.Name
+
+	case "user":
+		// Users may have a path before the name
+		principal.Name = parts[len(parts)-1]
+	}
+
+	return principal, nil
+}